@@ -19,7 +19,12 @@ var nextHandle = 1
 func OpenDB(pathCStr *C.char, dimension C.int) C.int {
 	path := C.GoString(pathCStr)
 
-	db, err := storage.Open(path, uint32(dimension))
+	db, err := storage.OpenWithOptions(path, uint32(dimension), storage.OpenOptions{
+		LazyLoad:      true,
+		CacheSize:     100,
+		Ingest:        storage.NewChannelQueue(1024),
+		IngestWorkers: 1,
+	})
 	if err != nil {
 		return -1
 	}
@@ -119,6 +124,40 @@ func FindSimilar(handle C.int, vectorPtr *C.float, vectorLen C.int, threshold C.
 	return 0
 }
 
+//export InsertAsync
+func InsertAsync(handle C.int, textCStr *C.char, vectorPtr *C.float, vectorLen C.int) C.int {
+	db, ok := dbCache[int(handle)]
+	if !ok {
+		return -1
+	}
+
+	text := C.GoString(textCStr)
+
+	vector := (*[1 << 30]float32)(unsafe.Pointer(vectorPtr))[:vectorLen:vectorLen]
+	vectorCopy := make([]float32, vectorLen)
+	copy(vectorCopy, vector)
+
+	if err := db.IngestAsync(text, vectorCopy); err != nil {
+		return -1
+	}
+
+	return 0
+}
+
+//export FlushIngest
+func FlushIngest(handle C.int) C.int {
+	db, ok := dbCache[int(handle)]
+	if !ok {
+		return -1
+	}
+
+	if err := db.FlushIngest(); err != nil {
+		return -1
+	}
+
+	return 0
+}
+
 //export GetStats
 func GetStats(handle C.int) *C.char {
 	db, ok := dbCache[int(handle)]
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// ErrMmapUnsupported is returned by Backend.MmapRegion when the backend
+// has no way to provide a zero-copy mapping (e.g. a remote object
+// store); callers must fall back to Backend.ReadAt instead.
+var ErrMmapUnsupported = errors.New("storage: backend does not support MmapRegion")
+
+// ErrBackendReadOnly is returned by a Backend's WriteAt/Truncate, and by
+// DB write methods (Insert, Write, Delete, Compact, ...), when the DB
+// was opened against any Backend via OpenOptions.Backend (such a DB is
+// always read-only, even over a writable LocalBackend).
+var ErrBackendReadOnly = errors.New("storage: backend is read-only")
+
+// Backend abstracts the storage medium a DB reads (and, for a local
+// file, writes) its records from, following how gotosocial's storage
+// layer abstracts disk vs S3 behind a common interface. The existing
+// single-growing-file/segment code in this package talks to a local
+// file directly for its hot write path; Backend exists so a DB can
+// instead be opened read-only against something else entirely - for
+// now, an HTTP range-read backend (HTTPRangeBackend) - via
+// OpenOptions.Backend.
+type Backend interface {
+	ReadAt(p []byte, off int64) (int, error)
+	WriteAt(p []byte, off int64) (int, error)
+	Size() (int64, error)
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+
+	// MmapRegion returns [offset, offset+length) mapped into memory for
+	// zero-copy reads, or ErrMmapUnsupported if the backend can't
+	// provide one.
+	MmapRegion(offset, length int64) ([]byte, error)
+}
+
+// LocalBackend is a Backend over a local *os.File, via the same mmap
+// machinery the rest of this package uses directly for the head
+// segment and sealed segments.
+type LocalBackend struct {
+	file *os.File
+}
+
+// OpenLocalBackend opens path as a LocalBackend.
+func OpenLocalBackend(path string, flag int, perm os.FileMode) (*LocalBackend, error) {
+	f, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalBackend{file: f}, nil
+}
+
+func (b *LocalBackend) ReadAt(p []byte, off int64) (int, error)  { return b.file.ReadAt(p, off) }
+func (b *LocalBackend) WriteAt(p []byte, off int64) (int, error) { return b.file.WriteAt(p, off) }
+func (b *LocalBackend) Truncate(size int64) error                { return b.file.Truncate(size) }
+func (b *LocalBackend) Sync() error                              { return b.file.Sync() }
+func (b *LocalBackend) Close() error                              { return b.file.Close() }
+
+func (b *LocalBackend) Size() (int64, error) {
+	stat, err := b.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+func (b *LocalBackend) MmapRegion(offset, length int64) ([]byte, error) {
+	return syscall.Mmap(
+		int(b.file.Fd()),
+		offset,
+		int(length),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+}
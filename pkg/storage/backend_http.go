@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// httpBackendPageSize is the granularity HTTPRangeBackend fetches and
+// caches pages at. Every Get/FindSimilar touches the header and index
+// section repeatedly, so caching whole pages - rather than the exact
+// bytes requested - turns most repeat reads into cache hits instead of
+// a fresh round trip.
+const httpBackendPageSize = 64 * 1024
+
+// defaultHTTPBackendMaxPages bounds HTTPRangeBackend's page cache to
+// roughly 16MB (256 pages at the default page size), so an unbounded
+// series of distant reads can't grow it forever.
+const defaultHTTPBackendMaxPages = 256
+
+// HTTPRangeBackend is a read-only Backend over a single object reachable
+// via HTTP range GET requests (e.g. an S3 object URL), with a small
+// bounded in-memory page cache so repeated reads of the same region
+// don't re-fetch over the network every time. It has no mmap
+// equivalent, so MmapRegion always returns ErrMmapUnsupported; DB falls
+// back to reading through it via readVectorAt/recordSizeAt/buildIndexAt
+// instead (see DB.openFromBackend).
+type HTTPRangeBackend struct {
+	url    string
+	client *http.Client
+	size   int64
+
+	mu       sync.Mutex
+	pages    map[int64][]byte
+	order    []int64 // least-recently-used first
+	maxPages int
+}
+
+// NewHTTPRangeBackend opens url as an HTTPRangeBackend, issuing a HEAD
+// request to learn its size. client defaults to http.DefaultClient, and
+// maxPages to defaultHTTPBackendMaxPages, if zero/nil.
+func NewHTTPRangeBackend(url string, client *http.Client, maxPages int) (*HTTPRangeBackend, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if maxPages <= 0 {
+		maxPages = defaultHTTPBackendMaxPages
+	}
+
+	b := &HTTPRangeBackend{
+		url:      url,
+		client:   client,
+		pages:    make(map[int64][]byte),
+		maxPages: maxPages,
+	}
+
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("storage: %s did not report a Content-Length", url)
+	}
+	b.size = resp.ContentLength
+
+	return b, nil
+}
+
+func (b *HTTPRangeBackend) Size() (int64, error) { return b.size, nil }
+
+func (b *HTTPRangeBackend) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pageIndex := (off + int64(n)) / httpBackendPageSize
+		pageStart := pageIndex * httpBackendPageSize
+
+		page, err := b.getPage(pageIndex)
+		if err != nil {
+			return n, err
+		}
+
+		pageOffset := off + int64(n) - pageStart
+		if pageOffset >= int64(len(page)) {
+			return n, io.EOF
+		}
+
+		copied := copy(p[n:], page[pageOffset:])
+		if copied == 0 {
+			return n, io.EOF
+		}
+		n += copied
+	}
+	return n, nil
+}
+
+func (b *HTTPRangeBackend) getPage(index int64) ([]byte, error) {
+	b.mu.Lock()
+	if page, ok := b.pages[index]; ok {
+		b.touch(index)
+		b.mu.Unlock()
+		return page, nil
+	}
+	b.mu.Unlock()
+
+	start := index * httpBackendPageSize
+	end := start + httpBackendPageSize - 1
+
+	req, err := http.NewRequest(http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.cachePage(index, page)
+	b.mu.Unlock()
+
+	return page, nil
+}
+
+// cachePage stores page under index, evicting the least-recently-used
+// page first once b.maxPages is exceeded. Callers must hold b.mu.
+func (b *HTTPRangeBackend) cachePage(index int64, page []byte) {
+	if _, exists := b.pages[index]; !exists {
+		if len(b.order) >= b.maxPages {
+			oldest := b.order[0]
+			b.order = b.order[1:]
+			delete(b.pages, oldest)
+		}
+		b.order = append(b.order, index)
+	}
+	b.pages[index] = page
+}
+
+// touch moves index to the most-recently-used end of b.order. Callers
+// must hold b.mu.
+func (b *HTTPRangeBackend) touch(index int64) {
+	for i, v := range b.order {
+		if v == index {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			b.order = append(b.order, index)
+			break
+		}
+	}
+}
+
+func (b *HTTPRangeBackend) WriteAt(p []byte, off int64) (int, error) { return 0, ErrBackendReadOnly }
+func (b *HTTPRangeBackend) Truncate(size int64) error                { return ErrBackendReadOnly }
+func (b *HTTPRangeBackend) Sync() error                              { return nil }
+func (b *HTTPRangeBackend) Close() error                             { return nil }
+
+func (b *HTTPRangeBackend) MmapRegion(offset, length int64) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
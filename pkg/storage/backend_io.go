@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrBackendRequiresIndex is returned by buildIndexAt when a Backend has
+// no MmapRegion support and the file it's opening predates having ever
+// written an index section (buildIndexLegacyOf's raw scan reads every
+// record byte-by-byte, which is impractical over a round-trip-per-read
+// backend like HTTPRangeBackend).
+var ErrBackendRequiresIndex = errors.New("storage: backend without MmapRegion requires a file with a written index section")
+
+// readVectorAt is the Backend-mediated counterpart to readVectorFrom: it
+// decodes the vector stored at offset by issuing ReadAt calls against r
+// instead of slicing an mmap directly, for a DB opened against a Backend
+// that couldn't provide one (see DB.openFromBackend).
+func readVectorAt(r Backend, header *Header, offset int64) ([]float32, error) {
+	if header.Version >= 3 {
+		prefix := make([]byte, RecordHeaderV3Size)
+		if _, err := r.ReadAt(prefix, offset); err != nil {
+			return nil, err
+		}
+
+		codec := CodecKind(prefix[8] &^ tombstoneCodecBit)
+		compressedLen := binary.LittleEndian.Uint32(prefix[9:13])
+		uncompressedLen := binary.LittleEndian.Uint32(prefix[13:17])
+
+		encoded := make([]byte, compressedLen)
+		if _, err := r.ReadAt(encoded, offset+int64(RecordHeaderV3Size)); err != nil {
+			return nil, err
+		}
+
+		return decodeVector(codec, uncompressedLen/4, encoded, uncompressedLen)
+	}
+
+	dimBuf := make([]byte, 4)
+	if _, err := r.ReadAt(dimBuf, offset+8); err != nil {
+		return nil, err
+	}
+	dim := binary.LittleEndian.Uint32(dimBuf) &^ tombstoneDimBit
+
+	payload := make([]byte, int64(dim)*4)
+	if _, err := r.ReadAt(payload, offset+RecordMetaSize); err != nil {
+		return nil, err
+	}
+
+	vector := make([]float32, dim)
+	for i := range vector {
+		bits := binary.LittleEndian.Uint32(payload[i*4:])
+		vector[i] = math.Float32frombits(bits)
+	}
+	return vector, nil
+}
+
+// recordSizeAt is the Backend-mediated counterpart to recordSizeFrom.
+func recordSizeAt(r Backend, header *Header, offset int64) (int64, error) {
+	if header.Version >= 3 {
+		buf := make([]byte, 4)
+		if _, err := r.ReadAt(buf, offset+9); err != nil {
+			return 0, err
+		}
+		compressedLen := binary.LittleEndian.Uint32(buf)
+		return int64(RecordHeaderV3Size) + int64(compressedLen), nil
+	}
+
+	buf := make([]byte, 4)
+	if _, err := r.ReadAt(buf, offset+8); err != nil {
+		return 0, err
+	}
+	dim := binary.LittleEndian.Uint32(buf) &^ tombstoneDimBit
+	return RecordMetaSize + int64(dim)*4, nil
+}
+
+// buildIndexAt rebuilds a hash->offset index for a Backend with no
+// MmapRegion support, by reading the persisted index section through
+// ReadAt. Unlike buildIndexFrom, it can't fall back to a raw legacy scan:
+// that would cost one round trip per record over a backend like
+// HTTPRangeBackend, so a file with no index section yet is rejected with
+// ErrBackendRequiresIndex instead.
+func buildIndexAt(r Backend, header *Header, size int64) (map[uint64]int64, error) {
+	if header.Version < 2 || header.IndexOffset == 0 {
+		return nil, ErrBackendRequiresIndex
+	}
+
+	indexOffset := int64(header.IndexOffset)
+
+	indexEnd := size
+	if header.BloomOffset > 0 {
+		indexEnd = int64(header.BloomOffset)
+	}
+	numEntries := (indexEnd - indexOffset) / IndexEntrySize
+
+	index := make(map[uint64]int64, numEntries)
+	buf := make([]byte, IndexEntrySize)
+	for i := int64(0); i < numEntries; i++ {
+		offset := indexOffset + i*IndexEntrySize
+		if _, err := r.ReadAt(buf, offset); err != nil {
+			return nil, err
+		}
+		entry := DecodeIndexEntry(buf)
+		index[entry.Hash] = entry.Offset
+	}
+
+	return index, nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// prepareBackendFixture writes a DB to tmpfile with an index section (so
+// it can later be opened read-only through a Backend with no MmapRegion
+// support) plus enough filler records to exceed httpBackendPageSize, so
+// TestHTTPRangeBackend_ReadAt can exercise a read spanning a page
+// boundary.
+func prepareBackendFixture(t *testing.T, tmpfile string) {
+	t.Helper()
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create fixture database: %v", err)
+	}
+	if err := db.Insert("hello", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Insert("world", []float32{4, 5, 6}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	for i := 0; i < 3000; i++ {
+		text := "filler-" + strconv.Itoa(i)
+		if err := db.Insert(text, []float32{1, 2, 3}); err != nil {
+			t.Fatalf("Failed to insert filler record %d: %v", i, err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close fixture database: %v", err)
+	}
+}
+
+func TestDB_OpenWithLocalBackend(t *testing.T) {
+	tmpfile := "test_backend_local.edb"
+	defer os.Remove(tmpfile)
+
+	prepareBackendFixture(t, tmpfile)
+
+	backend, err := OpenLocalBackend(tmpfile, os.O_RDONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenLocalBackend failed: %v", err)
+	}
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("OpenWithOptions with Backend failed: %v", err)
+	}
+	defer db.Close()
+
+	vector, err := db.Get("hello")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(vector) != 3 || vector[0] != 1 {
+		t.Errorf("Expected [1 2 3], got %v", vector)
+	}
+
+	if err := db.Insert("nope", []float32{0, 0, 0}); err != ErrBackendReadOnly {
+		t.Errorf("Expected ErrBackendReadOnly from Insert against a Backend-opened DB, got %v", err)
+	}
+	if err := db.Delete("hello"); err != ErrBackendReadOnly {
+		t.Errorf("Expected ErrBackendReadOnly from Delete against a Backend-opened DB, got %v", err)
+	}
+}
+
+func TestHTTPRangeBackend_ReadAt(t *testing.T) {
+	tmpfile := "test_backend_http.edb"
+	defer os.Remove(tmpfile)
+
+	prepareBackendFixture(t, tmpfile)
+
+	data, err := os.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatalf("Failed to read fixture file: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, tmpfile, time.Now(), bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	backend, err := NewHTTPRangeBackend(server.URL, server.Client(), 4)
+	if err != nil {
+		t.Fatalf("NewHTTPRangeBackend failed: %v", err)
+	}
+	defer backend.Close()
+
+	size, err := backend.Size()
+	if err != nil {
+		t.Fatalf("Size failed: %v", err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("Expected Size %d, got %d", len(data), size)
+	}
+
+	// Read a range that spans a page boundary, to exercise both the
+	// cache-miss fetch and the copy-across-pages loop in ReadAt.
+	start := int64(httpBackendPageSize) - 8
+	want := data[start : start+16]
+
+	got := make([]byte, 16)
+	n, err := backend.ReadAt(got, start)
+	if err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	if n != 16 {
+		t.Errorf("Expected to read 16 bytes, got %d", n)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ReadAt mismatch at %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	if _, err := backend.WriteAt(got, 0); err != ErrBackendReadOnly {
+		t.Errorf("Expected ErrBackendReadOnly from WriteAt, got %v", err)
+	}
+	if _, err := backend.MmapRegion(0, size); err != ErrMmapUnsupported {
+		t.Errorf("Expected ErrMmapUnsupported from MmapRegion, got %v", err)
+	}
+}
+
+func TestDB_OpenWithHTTPRangeBackend(t *testing.T) {
+	tmpfile := "test_backend_http_db.edb"
+	defer os.Remove(tmpfile)
+
+	prepareBackendFixture(t, tmpfile)
+
+	data, err := os.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatalf("Failed to read fixture file: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, tmpfile, time.Now(), bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	backend, err := NewHTTPRangeBackend(server.URL, server.Client(), 4)
+	if err != nil {
+		t.Fatalf("NewHTTPRangeBackend failed: %v", err)
+	}
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("OpenWithOptions with HTTPRangeBackend failed: %v", err)
+	}
+	defer db.Close()
+
+	vector, err := db.Get("world")
+	if err != nil {
+		t.Fatalf("Get through HTTPRangeBackend failed: %v", err)
+	}
+	if len(vector) != 3 || vector[0] != 4 {
+		t.Errorf("Expected [4 5 6], got %v", vector)
+	}
+}
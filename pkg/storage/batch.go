@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// batchOpKind identifies the kind of operation buffered in a Batch.
+type batchOpKind byte
+
+const (
+	batchPut batchOpKind = iota
+	batchDelete
+)
+
+// batchOp is a single buffered operation within a Batch.
+type batchOp struct {
+	kind   batchOpKind
+	hash   uint64
+	vector []float32
+}
+
+// Batch buffers a sequence of Put/Delete operations so they can be
+// applied to a DB atomically with a single disk write, modeled on
+// goleveldb's leveldb.Batch: accumulate operations with Put/Delete, then
+// hand the batch to DB.Write.
+type Batch struct {
+	ops []batchOp
+}
+
+// NewBatch returns an empty Batch ready to accumulate operations.
+func (db *DB) NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Put buffers an insert of vector under text. As with DB.Insert, the
+// vector's dimension is only validated when the batch is written.
+func (b *Batch) Put(text string, vector []float32) {
+	b.ops = append(b.ops, batchOp{kind: batchPut, hash: HashText(text), vector: vector})
+}
+
+// Delete buffers the removal of text's entry.
+func (b *Batch) Delete(text string) {
+	b.ops = append(b.ops, batchOp{kind: batchDelete, hash: HashText(text)})
+}
+
+// Len reports the number of buffered operations.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset clears the batch so it can be reused for another round of writes.
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// Write applies every operation buffered in b atomically: the combined
+// record bytes are appended to the data section with a single WriteAt,
+// and the in-memory index and LRU cache are only touched after that
+// write returns success. If any buffered Put has the wrong dimension,
+// the whole batch is rejected and no state - on disk or in memory - is
+// changed.
+func (db *DB) Write(b *Batch) error {
+	if b.Len() == 0 {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.backend != nil {
+		return ErrBackendReadOnly
+	}
+
+	type placedPut struct {
+		hash   uint64
+		offset int64
+	}
+
+	var puts []placedPut
+	var deletes []uint64
+	var buf []byte
+	offset := db.dataEndOffset
+
+	for _, op := range b.ops {
+		switch op.kind {
+		case batchPut:
+			if len(op.vector) != int(db.dimension) {
+				return fmt.Errorf("%w: expected %d, got %d",
+					ErrDimensionMismatch, db.dimension, len(op.vector))
+			}
+
+			if db.hashExists(op.hash) {
+				continue
+			}
+
+			rec, err := db.encodeRecord(op.hash, op.vector)
+			if err != nil {
+				return err
+			}
+
+			puts = append(puts, placedPut{hash: op.hash, offset: offset})
+			buf = append(buf, rec...)
+			offset += int64(len(rec))
+
+		case batchDelete:
+			deletes = append(deletes, op.hash)
+		}
+	}
+
+	if len(buf) > 0 {
+		// Pre-grow the file in one call rather than letting WriteAt
+		// extend it implicitly, so the single write below never races
+		// with a separate extending syscall.
+		if err := db.file.Truncate(db.dataEndOffset + int64(len(buf))); err != nil {
+			return err
+		}
+		if _, err := db.file.WriteAt(buf, db.dataEndOffset); err != nil {
+			// Nothing in db.index/db.cache was touched yet, so the DB is
+			// left exactly as it was before Write was called.
+			return err
+		}
+	}
+
+	// The write succeeded, so it's now safe to commit the in-memory
+	// index and cache.
+	for _, p := range puts {
+		db.index[p.hash] = p.offset
+		db.bloomAdd(p.hash)
+		db.header.RecordCount++
+	}
+	for _, hash := range deletes {
+		if oldOffset, exists := db.index[hash]; exists {
+			if size, err := db.recordSize(oldOffset); err == nil {
+				db.headDeadBytes += size
+			}
+			_ = db.tombstoneRecord(oldOffset)
+			delete(db.index, hash)
+			db.cache.Delete(hash, nil)
+			continue
+		}
+
+		// Not in the head; it may have already been sealed into a
+		// segment. Drop the hash from that segment's in-memory index
+		// and rewrite its on-disk index section to match, same as
+		// DeleteByHash, so the deletion survives a reopen.
+		for i := len(db.segments) - 1; i >= 0; i-- {
+			seg := db.segments[i]
+			if oldOffset, exists := seg.index[hash]; exists {
+				if size, err := recordSizeFrom(seg.mmap, seg.header, oldOffset); err == nil {
+					seg.deadBytes += size
+					db.segDeadBytes += size
+				}
+				delete(seg.index, hash)
+				_ = db.persistSegmentDelete(seg)
+				break
+			}
+		}
+		db.cache.Delete(hash, nil)
+	}
+
+	db.dataEndOffset = offset
+
+	if err := db.syncBatch(); err != nil {
+		return err
+	}
+
+	if db.segmentSizeThreshold > 0 && db.dataEndOffset-HeaderSize >= db.segmentSizeThreshold {
+		return db.sealHead()
+	}
+
+	if len(buf) > 0 {
+		if err := db.remap(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BatchItem is a single text/vector pair for InsertBatch.
+type BatchItem struct {
+	Text   string
+	Vector []float32
+}
+
+// InsertBatch inserts every item in one batch, amortizing the
+// WriteAt/remap/fsync cost across the whole slice the way repeated
+// Insert calls can't. It's a convenience wrapper around
+// NewBatch/Batch.Put/DB.Write for callers that already have every
+// text/vector pair in hand up front.
+func (db *DB) InsertBatch(items []BatchItem) error {
+	batch := db.NewBatch()
+	for _, item := range items {
+		batch.Put(item.Text, item.Vector)
+	}
+	return db.Write(batch)
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDB_BatchWrite(t *testing.T) {
+	tmpfile := "test_batch.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	b := db.NewBatch()
+	b.Put("first", []float32{0.1, 0.2, 0.3})
+	b.Put("second", []float32{0.4, 0.5, 0.6})
+
+	if err := db.Write(b); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	if got, err := db.Get("first"); err != nil || got[0] != 0.1 {
+		t.Errorf("Failed to get 'first' after batch write: %v %v", got, err)
+	}
+	if got, err := db.Get("second"); err != nil || got[0] != 0.4 {
+		t.Errorf("Failed to get 'second' after batch write: %v %v", got, err)
+	}
+
+	stats := db.Stats()
+	if stats["records"].(int) != 2 {
+		t.Errorf("Expected 2 records, got %d", stats["records"].(int))
+	}
+}
+
+func TestDB_BatchWriteRejectsDimensionMismatch(t *testing.T) {
+	tmpfile := "test_batch_mismatch.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	b := db.NewBatch()
+	b.Put("good", []float32{0.1, 0.2, 0.3})
+	b.Put("bad", []float32{0.1, 0.2})
+
+	if err := db.Write(b); err == nil {
+		t.Fatal("Expected batch write to fail on dimension mismatch")
+	}
+
+	// The whole batch should have been rejected, including the valid op.
+	if _, err := db.Get("good"); err != ErrNotFound {
+		t.Errorf("Expected 'good' to be absent after a rejected batch, got err=%v", err)
+	}
+}
+
+func TestDB_BatchDelete(t *testing.T) {
+	tmpfile := "test_batch_delete.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("text", []float32{0.1, 0.2, 0.3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	b := db.NewBatch()
+	b.Delete("text")
+	if err := db.Write(b); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	if _, err := db.Get("text"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after batch delete, got %v", err)
+	}
+}
+
+func TestDB_InsertBatch(t *testing.T) {
+	tmpfile := "test_insert_batch.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	items := []BatchItem{
+		{Text: "first", Vector: []float32{0.1, 0.2, 0.3}},
+		{Text: "second", Vector: []float32{0.4, 0.5, 0.6}},
+	}
+	if err := db.InsertBatch(items); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	if got, err := db.Get("first"); err != nil || got[0] != 0.1 {
+		t.Errorf("Failed to get 'first' after InsertBatch: %v %v", got, err)
+	}
+	if got, err := db.Get("second"); err != nil || got[0] != 0.4 {
+		t.Errorf("Failed to get 'second' after InsertBatch: %v %v", got, err)
+	}
+
+	stats := db.Stats()
+	if stats["records"].(int) != 2 {
+		t.Errorf("Expected 2 records, got %d", stats["records"].(int))
+	}
+}
+
+func TestDB_SyncEachFsyncsEveryInsert(t *testing.T) {
+	tmpfile := "test_sync_each.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SyncMode: SyncEach})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// SyncEach just needs to not error and still behave like a normal
+	// Insert; the actual fsync call itself isn't independently
+	// observable from here.
+	if err := db.Insert("hello", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Insert with SyncMode=SyncEach failed: %v", err)
+	}
+	if _, err := db.Get("hello"); err != nil {
+		t.Errorf("Expected 'hello' to be readable: %v", err)
+	}
+}
+
+func TestDB_SyncBatchFsyncsOncePerWrite(t *testing.T) {
+	tmpfile := "test_sync_batch.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SyncMode: SyncBatch})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	items := []BatchItem{
+		{Text: "a", Vector: []float32{1, 2, 3}},
+		{Text: "b", Vector: []float32{4, 5, 6}},
+	}
+	if err := db.InsertBatch(items); err != nil {
+		t.Fatalf("InsertBatch with SyncMode=SyncBatch failed: %v", err)
+	}
+	for _, text := range []string{"a", "b"} {
+		if _, err := db.Get(text); err != nil {
+			t.Errorf("Expected %q to be readable: %v", text, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package storage
+
+// bloomBitsPerKey and bloomHashCount follow LevelDB's table filter
+// block: ~10 bits per key with 7 hash functions give roughly a 1% false
+// positive rate.
+const bloomBitsPerKey = 10
+const bloomHashCount = 7
+
+// bloomFilter is a fixed-size Bloom filter over the uint64 record
+// hashes already used throughout this package, letting Get (and, once a
+// lookup needs to span many sealed segments, a future per-segment
+// check) skip a hash map lookup entirely when a hash is definitely
+// absent. Probes use the double-hashing scheme from Kirsch &
+// Mitzenmacher: bit i is derived from h1 + i*h2 mod numBits, where
+// h1/h2 come from splitting a 64-bit mix of the record hash (not the
+// raw hash's upper/lower halves - a record hash with a zero top or
+// bottom 32 bits would otherwise collapse every probe onto the same
+// handful of bits).
+type bloomFilter struct {
+	bits    []byte
+	numBits uint32
+}
+
+// newBloomFilter sizes an empty filter for n keys at ~bloomBitsPerKey
+// bits/key.
+func newBloomFilter(n int) *bloomFilter {
+	numBits := uint32(n * bloomBitsPerKey)
+	if numBits < 64 {
+		numBits = 64
+	}
+
+	numBytes := (numBits + 7) / 8
+	numBits = numBytes * 8
+
+	return &bloomFilter{bits: make([]byte, numBytes), numBits: numBits}
+}
+
+// buildBloomFilter builds a filter over every hash in index, sized for
+// exactly len(index) keys.
+func buildBloomFilter(index map[uint64]int64) *bloomFilter {
+	return buildBloomFilterWithCapacity(index, len(index))
+}
+
+// buildBloomFilterWithCapacity builds a filter over every hash in
+// index, sized for capacity keys rather than len(index) - used by
+// bloomAdd to rebuild with headroom instead of exactly-to-fit.
+func buildBloomFilterWithCapacity(index map[uint64]int64, capacity int) *bloomFilter {
+	f := newBloomFilter(capacity)
+	for hash := range index {
+		f.Add(hash)
+	}
+	return f
+}
+
+// bloomMix64 is the 64-bit finalizer from MurmurHash3/splitmix64: it
+// spreads every input bit across the whole output, so h1/h2 below don't
+// degenerate just because the input hash happens to have a zero top or
+// bottom half.
+func bloomMix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func bloomProbes(hash uint64) (h1, h2 uint32) {
+	mixed := bloomMix64(hash)
+	h1 = uint32(mixed >> 32)
+	h2 = uint32(mixed)
+	if h2 == 0 {
+		// h2 == 0 would collapse every probe onto bit h1 (i*h2 always 0).
+		h2 = 1
+	}
+	return h1, h2
+}
+
+func (f *bloomFilter) Add(hash uint64) {
+	h1, h2 := bloomProbes(hash)
+	for i := uint32(0); i < bloomHashCount; i++ {
+		bit := (h1 + i*h2) % f.numBits
+		f.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// MayContain reports whether hash might be in the filter. A false
+// return means hash is definitely absent; a true return means it might
+// be present (subject to the filter's false positive rate) and the
+// caller still needs to check the real index.
+func (f *bloomFilter) MayContain(hash uint64) bool {
+	h1, h2 := bloomProbes(hash)
+	for i := uint32(0); i < bloomHashCount; i++ {
+		bit := (h1 + i*h2) % f.numBits
+		if f.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) encode() []byte {
+	return f.bits
+}
+
+func decodeBloomFilter(buf []byte) *bloomFilter {
+	return &bloomFilter{bits: buf, numBits: uint32(len(buf)) * 8}
+}
+
+// bloomAdd records hash in db.bloom, lazily creating one on the first
+// insert into a fresh (or just-sealed) empty head. Insert, insertRaw and
+// Batch.Write all call this alongside adding hash to db.index, so the
+// filter never falls behind the index it's meant to pre-filter lookups
+// against. Once the head's grown past what the current filter was sized
+// for, it's rebuilt with 2x headroom - the same amortized-doubling
+// reasoning as a growing slice - rather than left undersized for the
+// rest of the head's life (a fixed 1-key filter would report almost
+// every hash as a false positive once the head holds more than a
+// handful of records, making it a useless pre-filter).
+func (db *DB) bloomAdd(hash uint64) {
+	n := len(db.index)
+	if db.bloom == nil || uint32(n*bloomBitsPerKey) > db.bloom.numBits {
+		db.bloom = buildBloomFilterWithCapacity(db.index, n*2)
+		return
+	}
+	db.bloom.Add(hash)
+}
+
+// loadOrRebuildBloom returns the Bloom filter persisted in mmap at
+// header's BloomOffset/BloomLength, or - for a file written before this
+// package wrote one (header.BloomOffset == 0) - rebuilds it in memory
+// from index. The rebuilt filter isn't written back to disk immediately;
+// it becomes persisted the next time writeIndexSectionTo runs for this
+// file (Close, sealHead, or a Compact/merge pass).
+//
+// The bytes are copied out of mmap rather than sliced from it directly:
+// for the head's filter in particular, bloomAdd later writes into
+// db.bloom.bits, and remap() can munmap this very region out from under
+// a slice that still aliased it.
+func loadOrRebuildBloom(mmap []byte, header *Header, index map[uint64]int64) *bloomFilter {
+	if header.BloomOffset > 0 && header.BloomLength > 0 {
+		start := int64(header.BloomOffset)
+		end := start + int64(header.BloomLength)
+		if end <= int64(len(mmap)) {
+			return decodeBloomFilter(append([]byte(nil), mmap[start:end]...))
+		}
+	}
+	if len(index) == 0 {
+		return nil
+	}
+	return buildBloomFilter(index)
+}
+
+// loadOrRebuildBloomAt is the Backend-mediated counterpart to
+// loadOrRebuildBloom, for a DB opened against a Backend with no
+// MmapRegion support.
+func loadOrRebuildBloomAt(r Backend, header *Header, index map[uint64]int64) (*bloomFilter, error) {
+	if header.BloomOffset > 0 && header.BloomLength > 0 {
+		buf := make([]byte, header.BloomLength)
+		if _, err := r.ReadAt(buf, int64(header.BloomOffset)); err != nil {
+			return nil, err
+		}
+		return decodeBloomFilter(buf), nil
+	}
+	if len(index) == 0 {
+		return nil, nil
+	}
+	return buildBloomFilter(index), nil
+}
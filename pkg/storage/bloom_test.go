@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestBloomFilter_MayContain(t *testing.T) {
+	present := []uint64{1, 2, 3, 42, 1000, HashText("hello"), HashText("world")}
+
+	filter := newBloomFilter(len(present))
+	for _, hash := range present {
+		filter.Add(hash)
+	}
+
+	for _, hash := range present {
+		if !filter.MayContain(hash) {
+			t.Errorf("Expected MayContain(%d) to be true for an added hash", hash)
+		}
+	}
+
+	// A filter sized for a handful of keys shouldn't report every
+	// unrelated hash as present.
+	falsePositives := 0
+	for i := uint64(100000); i < 101000; i++ {
+		if filter.MayContain(i) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 50 {
+		t.Errorf("Expected roughly a 1%% false positive rate, got %d/1000", falsePositives)
+	}
+}
+
+func TestBloomFilter_EncodeDecode(t *testing.T) {
+	filter := buildBloomFilter(map[uint64]int64{1: 0, 2: 16, 3: 32})
+
+	decoded := decodeBloomFilter(filter.encode())
+	for _, hash := range []uint64{1, 2, 3} {
+		if !decoded.MayContain(hash) {
+			t.Errorf("Expected decoded filter to contain %d", hash)
+		}
+	}
+}
+
+func TestDB_GetUsesBloomFilterAcrossReopen(t *testing.T) {
+	tmpfile := "test_bloom_reopen.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if err := db.Insert("hello", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.bloom == nil {
+		t.Fatal("Expected a Bloom filter to have been loaded on reopen")
+	}
+	if !reopened.bloom.MayContain(HashText("hello")) {
+		t.Error("Expected the Bloom filter to report 'hello' as possibly present")
+	}
+	if reopened.bloom.MayContain(HashText("definitely-not-present-xyz")) {
+		t.Error("Expected the Bloom filter to report an unrelated hash as absent")
+	}
+
+	if _, err := reopened.Get("hello"); err != nil {
+		t.Errorf("Expected 'hello' to be found after reopen: %v", err)
+	}
+	if _, err := reopened.Get("missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing key, got %v", err)
+	}
+}
+
+func TestDB_BloomFilterSurvivesRemapAfterReopen(t *testing.T) {
+	tmpfile := "test_bloom_remap.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if err := db.Insert("hello", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// On reopen, db.bloom is loaded straight out of the mmap'd file.
+	// Inserting enough new records to force several remaps (each of
+	// which munmaps the previous mapping) must not corrupt or crash
+	// while reading/writing the loaded filter - it must have been
+	// copied out of the mmap, not aliased to it.
+	reopened, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	for i := 0; i < 500; i++ {
+		text := "key-" + strconv.Itoa(i)
+		if err := reopened.Insert(text, []float32{float32(i), 0, 0}); err != nil {
+			t.Fatalf("Failed to insert %q: %v", text, err)
+		}
+	}
+
+	if !reopened.bloom.MayContain(HashText("hello")) {
+		t.Error("Expected the Bloom filter to still report 'hello' as possibly present")
+	}
+	if _, err := reopened.Get("hello"); err != nil {
+		t.Errorf("Expected 'hello' to still be found after remapping: %v", err)
+	}
+}
+
+func TestDB_BloomFilterResizesAsHeadGrows(t *testing.T) {
+	tmpfile := "test_bloom_resize.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	const n = 2000
+	for i := 0; i < n; i++ {
+		text := "key-" + strconv.Itoa(i)
+		if err := db.Insert(text, []float32{float32(i), 0, 0}); err != nil {
+			t.Fatalf("Failed to insert %q: %v", text, err)
+		}
+	}
+
+	if got := uint32(n * bloomBitsPerKey); db.bloom.numBits < got {
+		t.Errorf("Expected the live filter to have grown to cover %d keys, got numBits=%d", n, db.bloom.numBits)
+	}
+
+	falsePositives := 0
+	for i := n; i < n+1000; i++ {
+		if db.bloom.MayContain(HashText("key-" + strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 50 {
+		t.Errorf("Expected roughly a 1%% false positive rate once resized, got %d/1000", falsePositives)
+	}
+}
+
+func TestDB_GetSeesBloomFilterForFreshInsert(t *testing.T) {
+	tmpfile := "test_bloom_fresh_insert.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("hello", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if db.bloom == nil || !db.bloom.MayContain(HashText("hello")) {
+		t.Fatal("Expected the Bloom filter to be updated immediately on Insert")
+	}
+	if _, err := db.Get("hello"); err != nil {
+		t.Errorf("Expected a just-inserted record to still be found: %v", err)
+	}
+}
@@ -5,75 +5,296 @@ import (
 	"sync"
 )
 
-// LRU cache for hot vectors
-type LRUCache struct {
-	capacity int
-	cache    map[uint64]*list.Element
-	lru      *list.List
-	mu       sync.RWMutex
-}
+// PurgeFin is invoked once for each cache entry that is actually freed -
+// i.e. once its refcount has dropped to zero - whether that happens via
+// natural LRU eviction, an explicit Delete, or Purge/PurgeNamespace.
+type PurgeFin func()
 
+// cacheEntry is one cached vector, shared by every Handle referencing it.
 type cacheEntry struct {
-	hash   uint64
-	vector []float32
+	ns      *namespace
+	key     uint64
+	vector  []float32
+	refs    int
+	elem    *list.Element // non-nil only while refs == 0 (idle in the LRU list)
+	deleted bool
+	fin     PurgeFin
+}
+
+// Handle pins a cached vector so it can't be evicted while in use. The
+// caller must call Release once done with it.
+type Handle struct {
+	ns    *namespace
+	entry *cacheEntry
+	once  sync.Once
+}
+
+// Vector returns the handle's pinned vector. Valid until Release.
+func (h *Handle) Vector() []float32 {
+	return h.entry.vector
+}
+
+// Release drops this handle's reference to its entry. Safe to call more
+// than once; only the first call has effect.
+func (h *Handle) Release() {
+	h.once.Do(func() {
+		h.ns.release(h.entry)
+	})
+}
+
+// Namespace is a Cache scoped to one logical keyspace, typically one per
+// DB instance sharing a physical Cache.
+type Namespace interface {
+	// Get returns a Handle for key. On a miss, fill is called to
+	// produce the vector and the second return is false; on a hit fill
+	// is not called and the second return is true.
+	Get(key uint64, fill func() []float32) (handle *Handle, hit bool)
+	// Delete evicts key immediately. fin, if non-nil, runs once the
+	// entry's refcount reaches zero (immediately, if nothing currently
+	// holds a Handle to it). Reports whether key was present.
+	Delete(key uint64, fin PurgeFin) bool
+	// Purge evicts every entry belonging to this namespace.
+	Purge(fin PurgeFin)
 }
 
-func NewLRUCache(capacity int) *LRUCache {
-	return &LRUCache{
-		capacity: capacity,
-		cache:    make(map[uint64]*list.Element),
-		lru:      list.New(),
+// Cache is a namespaced, reference-counted vector cache modeled on
+// goleveldb's cache.Cache/Namespace API. Unlike a flat LRU, entries are
+// wrapped in a Handle with a refcount: Get returns a Handle the caller
+// must Release, and an entry is only actually freed once its refcount
+// drops to zero, even if it has fallen out of the LRU window in the
+// meantime. This lets multiple DB instances opened in one process share
+// a single physical Cache (one Namespace per DB) without one DB's
+// eviction freeing a vector that, say, a Snapshot in another DB still
+// needs.
+type Cache interface {
+	GetNamespace(id uint64) Namespace
+	PurgeNamespace(id uint64, fin PurgeFin)
+	Purge(fin PurgeFin)
+	Size() int       // number of live entries, pinned or idle
+	NumObjects() int // number of entries currently pinned by an outstanding Handle
+}
+
+type namespace struct {
+	id    uint64
+	cache *lruCache
+	nodes map[uint64]*cacheEntry
+}
+
+type lruCache struct {
+	mu         sync.Mutex
+	capacity   int
+	size       int
+	pinned     int // entries with refs > 0, i.e. held by at least one outstanding Handle
+	lru        *list.List // *cacheEntry values with refs == 0
+	namespaces map[uint64]*namespace
+}
+
+// NewCache returns a Cache that holds at most capacity idle (refs == 0)
+// entries before evicting the least recently used one. Entries with an
+// outstanding Handle are never evicted regardless of capacity.
+func NewCache(capacity int) Cache {
+	return &lruCache{
+		capacity:   capacity,
+		lru:        list.New(),
+		namespaces: make(map[uint64]*namespace),
 	}
 }
 
-func (c *LRUCache) Get(hash uint64) ([]float32, bool) {
+func (c *lruCache) GetNamespace(id uint64) Namespace {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if elem, ok := c.cache[hash]; ok {
-		c.lru.MoveToFront(elem)
-		return elem.Value.(*cacheEntry).vector, true
+	ns, ok := c.namespaces[id]
+	if !ok {
+		ns = &namespace{id: id, cache: c, nodes: make(map[uint64]*cacheEntry)}
+		c.namespaces[id] = ns
 	}
-	return nil, false
+	return ns
 }
 
-func (c *LRUCache) Put(hash uint64, vector []float32) {
+func (ns *namespace) Get(key uint64, fill func() []float32) (*Handle, bool) {
+	c := ns.cache
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	if entry, ok := ns.nodes[key]; ok {
+		c.pinLocked(entry)
+		c.mu.Unlock()
+		return &Handle{ns: ns, entry: entry}, true
+	}
+	c.mu.Unlock()
+
+	vector := fill()
 
-	if elem, ok := c.cache[hash]; ok {
-		c.lru.MoveToFront(elem)
-		elem.Value.(*cacheEntry).vector = vector
-		return
+	c.mu.Lock()
+	if entry, ok := ns.nodes[key]; ok {
+		// Lost a race with a concurrent fill for the same key; use the
+		// entry that won and discard our own.
+		c.pinLocked(entry)
+		c.mu.Unlock()
+		return &Handle{ns: ns, entry: entry}, true
 	}
 
-	entry := &cacheEntry{hash: hash, vector: vector}
-	elem := c.lru.PushFront(entry)
-	c.cache[hash] = elem
+	entry := &cacheEntry{ns: ns, key: key, vector: vector, refs: 1}
+	ns.nodes[key] = entry
+	c.size++
+	c.pinned++
+	fins := c.evictLocked()
+	c.mu.Unlock()
+
+	runFins(fins)
+	return &Handle{ns: ns, entry: entry}, false
+}
 
-	if c.lru.Len() > c.capacity {
-		c.evict()
+func (ns *namespace) Delete(key uint64, fin PurgeFin) bool {
+	c := ns.cache
+
+	c.mu.Lock()
+	entry, ok := ns.nodes[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
 	}
+
+	entry.fin = fin
+	entry.deleted = true
+	delete(ns.nodes, key) // unreachable to Get now, even while still pinned
+
+	var fins []PurgeFin
+	if entry.refs == 0 {
+		if entry.elem != nil {
+			c.lru.Remove(entry.elem)
+			entry.elem = nil
+		}
+		fins = c.removeLocked(entry)
+	}
+	c.mu.Unlock()
+
+	runFins(fins)
+	return true
 }
 
-func (c *LRUCache) evict() {
-	elem := c.lru.Back()
-	if elem != nil {
-		c.lru.Remove(elem)
-		entry := elem.Value.(*cacheEntry)
-		delete(c.cache, entry.hash)
+func (ns *namespace) Purge(fin PurgeFin) {
+	c := ns.cache
+
+	c.mu.Lock()
+	var fins []PurgeFin
+	for _, entry := range ns.nodes {
+		entry.fin = fin
+		entry.deleted = true
+		delete(ns.nodes, entry.key)
+		if entry.refs == 0 {
+			if entry.elem != nil {
+				c.lru.Remove(entry.elem)
+				entry.elem = nil
+			}
+			fins = append(fins, c.removeLocked(entry)...)
+		}
+	}
+	c.mu.Unlock()
+
+	runFins(fins)
+}
+
+func (c *lruCache) PurgeNamespace(id uint64, fin PurgeFin) {
+	c.mu.Lock()
+	ns, ok := c.namespaces[id]
+	c.mu.Unlock()
+
+	if ok {
+		ns.Purge(fin)
 	}
 }
 
-func (c *LRUCache) Len() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.lru.Len()
+func (c *lruCache) Purge(fin PurgeFin) {
+	c.mu.Lock()
+	namespaces := make([]*namespace, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	c.mu.Unlock()
+
+	for _, ns := range namespaces {
+		ns.Purge(fin)
+	}
 }
 
-func (c *LRUCache) Clear() {
+func (c *lruCache) Size() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.cache = make(map[uint64]*list.Element)
-	c.lru = list.New()
+	return c.size
+}
+
+func (c *lruCache) NumObjects() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pinned
+}
+
+// pinLocked increments entry's refcount and, if it was idle in the LRU
+// list, removes it so it can't be evicted while pinned. c.mu must be held.
+func (c *lruCache) pinLocked(entry *cacheEntry) {
+	entry.refs++
+	if entry.elem != nil {
+		c.lru.Remove(entry.elem)
+		entry.elem = nil
+		c.pinned++
+	}
+}
+
+// release drops entry's refcount, returning it to the LRU - or freeing
+// it immediately if it was marked deleted - once nothing references it.
+func (ns *namespace) release(entry *cacheEntry) {
+	c := ns.cache
+
+	c.mu.Lock()
+	entry.refs--
+
+	var fins []PurgeFin
+	if entry.refs == 0 {
+		c.pinned--
+		if entry.deleted {
+			fins = c.removeLocked(entry)
+		} else {
+			entry.elem = c.lru.PushFront(entry)
+			fins = c.evictLocked()
+		}
+	}
+	c.mu.Unlock()
+
+	runFins(fins)
+}
+
+// evictLocked evicts idle entries past capacity, returning their fins to
+// run once the lock is released. c.mu must be held.
+func (c *lruCache) evictLocked() []PurgeFin {
+	var fins []PurgeFin
+	for c.size > c.capacity && c.lru.Len() > 0 {
+		elem := c.lru.Back()
+		entry := elem.Value.(*cacheEntry)
+		c.lru.Remove(elem)
+		entry.elem = nil
+		fins = append(fins, c.removeLocked(entry)...)
+	}
+	return fins
+}
+
+// removeLocked deletes entry from its namespace and returns its fin (if
+// any) to run once the lock is released. c.mu must be held.
+func (c *lruCache) removeLocked(entry *cacheEntry) []PurgeFin {
+	// A deleted entry was already dropped from nodes, and a fresh entry
+	// may since have taken its key; only remove the mapping if it's ours.
+	if entry.ns.nodes[entry.key] == entry {
+		delete(entry.ns.nodes, entry.key)
+	}
+	c.size--
+	if entry.fin != nil {
+		return []PurgeFin{entry.fin}
+	}
+	return nil
+}
+
+func runFins(fins []PurgeFin) {
+	for _, fin := range fins {
+		fin()
+	}
 }
@@ -5,72 +5,139 @@ import (
 	"testing"
 )
 
-func TestLRUCache_Basic(t *testing.T) {
-	cache := NewLRUCache(3)
+func TestCache_GetFillsOnMiss(t *testing.T) {
+	cache := NewCache(3)
+	ns := cache.GetNamespace(1)
+
+	filled := false
+	handle, hit := ns.Get(1, func() []float32 {
+		filled = true
+		return []float32{1.0, 2.0}
+	})
+	defer handle.Release()
+
+	if hit {
+		t.Error("Expected a miss on first Get")
+	}
+	if !filled {
+		t.Error("Expected fill to be called on a miss")
+	}
+	if handle.Vector()[0] != 1.0 {
+		t.Errorf("Expected vector[0] == 1.0, got %f", handle.Vector()[0])
+	}
+}
 
-	// Add items
-	cache.Put(1, []float32{1.0, 2.0})
-	cache.Put(2, []float32{3.0, 4.0})
-	cache.Put(3, []float32{5.0, 6.0})
+func TestCache_GetHitsWithoutRefilling(t *testing.T) {
+	cache := NewCache(3)
+	ns := cache.GetNamespace(1)
 
-	if cache.Len() != 3 {
-		t.Errorf("Expected cache length 3, got %d", cache.Len())
-	}
+	h1, _ := ns.Get(1, func() []float32 { return []float32{1.0} })
+	h1.Release()
+
+	filled := false
+	h2, hit := ns.Get(1, func() []float32 {
+		filled = true
+		return []float32{9.0}
+	})
+	defer h2.Release()
 
-	// Get items
-	if vec, ok := cache.Get(1); !ok || vec[0] != 1.0 {
-		t.Error("Failed to get item 1")
+	if !hit {
+		t.Error("Expected a hit on second Get")
+	}
+	if filled {
+		t.Error("fill should not be called on a hit")
+	}
+	if h2.Vector()[0] != 1.0 {
+		t.Errorf("Expected cached vector[0] == 1.0, got %f", h2.Vector()[0])
 	}
 }
 
-func TestLRUCache_Eviction(t *testing.T) {
-	cache := NewLRUCache(2)
-
-	cache.Put(1, []float32{1.0})
-	cache.Put(2, []float32{2.0})
-	cache.Put(3, []float32{3.0}) // Should evict 1
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(2)
+	ns := cache.GetNamespace(1)
 
-	if cache.Len() != 2 {
-		t.Errorf("Expected cache length 2, got %d", cache.Len())
+	for _, key := range []uint64{1, 2, 3} {
+		h, _ := ns.Get(key, func() []float32 { return []float32{float32(key)} })
+		h.Release() // return to the idle LRU so it's eligible for eviction
 	}
 
-	// Item 1 should be evicted
-	if _, ok := cache.Get(1); ok {
-		t.Error("Item 1 should have been evicted")
+	if cache.Size() != 2 {
+		t.Errorf("Expected cache size 2 after evicting past capacity, got %d", cache.Size())
 	}
 
-	// Items 2 and 3 should still be there
-	if _, ok := cache.Get(2); !ok {
-		t.Error("Item 2 should still be in cache")
+	if _, hit := ns.Get(1, func() []float32 { return nil }); hit {
+		t.Error("Expected key 1 to have been evicted")
 	}
-	if _, ok := cache.Get(3); !ok {
-		t.Error("Item 3 should still be in cache")
+}
+
+func TestCache_PinnedEntryIsNotEvicted(t *testing.T) {
+	cache := NewCache(1)
+	ns := cache.GetNamespace(1)
+
+	pinned, _ := ns.Get(1, func() []float32 { return []float32{1.0} })
+	// pinned is never released, so it must survive even though capacity
+	// is exceeded by the next insert.
+	other, _ := ns.Get(2, func() []float32 { return []float32{2.0} })
+	other.Release()
+
+	if _, hit := ns.Get(1, func() []float32 { return nil }); !hit {
+		t.Error("Expected pinned entry to survive eviction pressure")
 	}
+	pinned.Release()
 }
 
-func TestLRUCache_LRUOrder(t *testing.T) {
-	cache := NewLRUCache(2)
+func TestCache_NumObjectsCountsOnlyPinnedEntries(t *testing.T) {
+	cache := NewCache(3)
+	ns := cache.GetNamespace(1)
 
-	cache.Put(1, []float32{1.0})
-	cache.Put(2, []float32{2.0})
+	pinned, _ := ns.Get(1, func() []float32 { return []float32{1.0} })
+	idle, _ := ns.Get(2, func() []float32 { return []float32{2.0} })
+	idle.Release()
 
-	// Access item 1 to make it recently used
-	cache.Get(1)
+	if cache.Size() != 2 {
+		t.Errorf("Expected Size to count both entries, got %d", cache.Size())
+	}
+	if cache.NumObjects() != 1 {
+		t.Errorf("Expected NumObjects to count only the pinned entry, got %d", cache.NumObjects())
+	}
 
-	// Add item 3 - should evict 2 (least recently used)
-	cache.Put(3, []float32{3.0})
+	pinned.Release()
 
-	// Item 2 should be evicted
-	if _, ok := cache.Get(2); ok {
-		t.Error("Item 2 should have been evicted")
+	if cache.NumObjects() != 0 {
+		t.Errorf("Expected NumObjects to drop to 0 once released, got %d", cache.NumObjects())
 	}
+}
 
-	// Items 1 and 3 should still be there
-	if _, ok := cache.Get(1); !ok {
-		t.Error("Item 1 should still be in cache")
+func TestCache_DeleteRunsFinOnceUnreferenced(t *testing.T) {
+	cache := NewCache(3)
+	ns := cache.GetNamespace(1)
+
+	h, _ := ns.Get(1, func() []float32 { return []float32{1.0} })
+
+	finRan := false
+	ns.Delete(1, func() { finRan = true })
+
+	if finRan {
+		t.Error("fin should not run while a Handle is still outstanding")
 	}
-	if _, ok := cache.Get(3); !ok {
-		t.Error("Item 3 should still be in cache")
+
+	h.Release()
+
+	if !finRan {
+		t.Error("fin should run once the last Handle is released after Delete")
+	}
+}
+
+func TestCache_NamespacesAreIsolated(t *testing.T) {
+	cache := NewCache(3)
+	nsA := cache.GetNamespace(1)
+	nsB := cache.GetNamespace(2)
+
+	ha, _ := nsA.Get(1, func() []float32 { return []float32{1.0} })
+	defer ha.Release()
+
+	if _, hit := nsB.Get(1, func() []float32 { return nil }); hit {
+		t.Error("Expected namespace B to not see namespace A's key 1")
 	}
 }
 
@@ -142,6 +209,40 @@ func TestDB_OpenWithOptions(t *testing.T) {
 	}
 }
 
+func TestDB_SharedCacheAcrossInstances(t *testing.T) {
+	tmpfile1 := "test_shared_cache_1.edb"
+	tmpfile2 := "test_shared_cache_2.edb"
+	defer os.Remove(tmpfile1)
+	defer os.Remove(tmpfile2)
+
+	shared := NewCache(100)
+
+	db1, err := OpenWithOptions(tmpfile1, 3, OpenOptions{SharedCache: shared})
+	if err != nil {
+		t.Fatalf("Failed to create database 1: %v", err)
+	}
+	defer db1.Close()
+
+	db2, err := OpenWithOptions(tmpfile2, 3, OpenOptions{SharedCache: shared})
+	if err != nil {
+		t.Fatalf("Failed to create database 2: %v", err)
+	}
+	defer db2.Close()
+
+	db1.Insert("only-in-db1", []float32{1, 2, 3})
+	if _, err := db1.Get("only-in-db1"); err != nil {
+		t.Fatalf("Failed to get from db1: %v", err)
+	}
+
+	if _, err := db2.Get("only-in-db1"); err != ErrNotFound {
+		t.Errorf("Expected db2's namespace to not see db1's record, got %v", err)
+	}
+
+	if shared.Size() == 0 {
+		t.Error("Expected the shared physical cache to hold at least one entry")
+	}
+}
+
 func BenchmarkDB_GetWithCache(b *testing.B) {
 	tmpfile := "bench_cache.edb"
 	defer os.Remove(tmpfile)
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/golang/snappy"
+)
+
+// CodecKind identifies how a single record's vector bytes are encoded
+// on disk. Introduced in format version 3, where each record carries
+// its own codec byte instead of every record in the file sharing one
+// implicit raw-float32 layout.
+type CodecKind byte
+
+const (
+	// CodecRaw stores the vector as uncompressed little-endian
+	// float32s, identical to the version-2 on-disk layout.
+	CodecRaw CodecKind = iota
+	// CodecSnappy stores the raw float32 bytes passed through Snappy.
+	// Best for vectors with repeated or low-entropy components.
+	CodecSnappy
+	// CodecInt8 quantizes each component to a signed byte using a
+	// single per-record scale factor, trading precision for a 4x size
+	// reduction.
+	CodecInt8
+	// CodecBinary keeps only the sign of each component, packed 8 per
+	// byte, for callers doing Hamming-distance style comparisons rather
+	// than cosine similarity.
+	CodecBinary
+)
+
+// encodeVector serializes vector into codec's on-disk form. uncompressedLen
+// is always len(vector)*4 and is returned so callers can populate a v3
+// record header without re-deriving it.
+func encodeVector(codec CodecKind, vector []float32) (encoded []byte, uncompressedLen uint32, err error) {
+	raw := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(v))
+	}
+	uncompressedLen = uint32(len(raw))
+
+	switch codec {
+	case CodecRaw:
+		return raw, uncompressedLen, nil
+	case CodecSnappy:
+		return snappy.Encode(nil, raw), uncompressedLen, nil
+	case CodecInt8:
+		return encodeInt8(vector), uncompressedLen, nil
+	case CodecBinary:
+		return encodeBinary(vector), uncompressedLen, nil
+	default:
+		return nil, 0, fmt.Errorf("storage: unknown codec %d", codec)
+	}
+}
+
+// decodeVector reverses encodeVector. dim is the original vector
+// dimension (derived by the caller from uncompressedLen/4), needed
+// because quantized and binary encodings don't map 1:1 back to a float
+// count from their byte length alone.
+func decodeVector(codec CodecKind, dim uint32, encoded []byte, uncompressedLen uint32) ([]float32, error) {
+	switch codec {
+	case CodecRaw:
+		return floatsFromRaw(encoded, dim), nil
+	case CodecSnappy:
+		raw, err := snappy.Decode(make([]byte, 0, uncompressedLen), encoded)
+		if err != nil {
+			return nil, fmt.Errorf("storage: snappy decode: %w", err)
+		}
+		return floatsFromRaw(raw, dim), nil
+	case CodecInt8:
+		return decodeInt8(encoded, dim), nil
+	case CodecBinary:
+		return decodeBinary(encoded, dim), nil
+	default:
+		return nil, fmt.Errorf("storage: unknown codec %d", codec)
+	}
+}
+
+func floatsFromRaw(raw []byte, dim uint32) []float32 {
+	vector := make([]float32, dim)
+	for i := range vector {
+		bits := binary.LittleEndian.Uint32(raw[i*4:])
+		vector[i] = math.Float32frombits(bits)
+	}
+	return vector
+}
+
+// encodeInt8 quantizes vector to signed bytes using a single scale
+// factor derived from its largest-magnitude component. The scale is
+// written as a leading float32 so decodeInt8 can invert it.
+func encodeInt8(vector []float32) []byte {
+	var maxAbs float32
+	for _, v := range vector {
+		if abs := float32(math.Abs(float64(v))); abs > maxAbs {
+			maxAbs = abs
+		}
+	}
+
+	scale := maxAbs / 127
+	if scale == 0 {
+		scale = 1
+	}
+
+	buf := make([]byte, 4+len(vector))
+	binary.LittleEndian.PutUint32(buf[0:4], math.Float32bits(scale))
+	for i, v := range vector {
+		q := int32(v / scale)
+		if q > 127 {
+			q = 127
+		} else if q < -128 {
+			q = -128
+		}
+		buf[4+i] = byte(int8(q))
+	}
+	return buf
+}
+
+func decodeInt8(encoded []byte, dim uint32) []float32 {
+	scale := math.Float32frombits(binary.LittleEndian.Uint32(encoded[0:4]))
+	vector := make([]float32, dim)
+	for i := range vector {
+		vector[i] = float32(int8(encoded[4+i])) * scale
+	}
+	return vector
+}
+
+// encodeBinary keeps only the sign of each component, packed 8 per byte.
+func encodeBinary(vector []float32) []byte {
+	buf := make([]byte, (len(vector)+7)/8)
+	for i, v := range vector {
+		if v >= 0 {
+			buf[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return buf
+}
+
+func decodeBinary(encoded []byte, dim uint32) []float32 {
+	vector := make([]float32, dim)
+	for i := range vector {
+		bit := (encoded[i/8] >> uint(i%8)) & 1
+		if bit == 1 {
+			vector[i] = 1
+		} else {
+			vector[i] = -1
+		}
+	}
+	return vector
+}
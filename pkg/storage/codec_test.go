@@ -0,0 +1,87 @@
+package storage
+
+import "testing"
+
+func TestCodec_RawRoundTrip(t *testing.T) {
+	vector := []float32{0.1, -0.2, 0.3, 1.5}
+
+	encoded, uncompressedLen, err := encodeVector(CodecRaw, vector)
+	if err != nil {
+		t.Fatalf("encodeVector failed: %v", err)
+	}
+
+	decoded, err := decodeVector(CodecRaw, uncompressedLen/4, encoded, uncompressedLen)
+	if err != nil {
+		t.Fatalf("decodeVector failed: %v", err)
+	}
+
+	for i := range vector {
+		if decoded[i] != vector[i] {
+			t.Errorf("index %d: expected %f, got %f", i, vector[i], decoded[i])
+		}
+	}
+}
+
+func TestCodec_SnappyRoundTrip(t *testing.T) {
+	vector := []float32{0.1, 0.1, 0.1, 0.1, 0.5, -0.5}
+
+	encoded, uncompressedLen, err := encodeVector(CodecSnappy, vector)
+	if err != nil {
+		t.Fatalf("encodeVector failed: %v", err)
+	}
+
+	decoded, err := decodeVector(CodecSnappy, uncompressedLen/4, encoded, uncompressedLen)
+	if err != nil {
+		t.Fatalf("decodeVector failed: %v", err)
+	}
+
+	for i := range vector {
+		if decoded[i] != vector[i] {
+			t.Errorf("index %d: expected %f, got %f", i, vector[i], decoded[i])
+		}
+	}
+}
+
+func TestCodec_Int8RoundTripApproximate(t *testing.T) {
+	vector := []float32{0.1, -0.2, 0.3, 1.0, -1.0}
+
+	encoded, uncompressedLen, err := encodeVector(CodecInt8, vector)
+	if err != nil {
+		t.Fatalf("encodeVector failed: %v", err)
+	}
+
+	decoded, err := decodeVector(CodecInt8, uncompressedLen/4, encoded, uncompressedLen)
+	if err != nil {
+		t.Fatalf("decodeVector failed: %v", err)
+	}
+
+	for i := range vector {
+		diff := decoded[i] - vector[i]
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > 0.05 {
+			t.Errorf("index %d: expected approximately %f, got %f", i, vector[i], decoded[i])
+		}
+	}
+}
+
+func TestCodec_BinaryRoundTripSign(t *testing.T) {
+	vector := []float32{0.1, -0.2, 0.3, -1.0, 1.0}
+
+	encoded, uncompressedLen, err := encodeVector(CodecBinary, vector)
+	if err != nil {
+		t.Fatalf("encodeVector failed: %v", err)
+	}
+
+	decoded, err := decodeVector(CodecBinary, uncompressedLen/4, encoded, uncompressedLen)
+	if err != nil {
+		t.Fatalf("decodeVector failed: %v", err)
+	}
+
+	for i := range vector {
+		if (vector[i] >= 0) != (decoded[i] >= 0) {
+			t.Errorf("index %d: sign mismatch, expected %f, got %f", i, vector[i], decoded[i])
+		}
+	}
+}
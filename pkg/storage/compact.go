@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+)
+
+// compactCheckInterval is how often the background compactor started by
+// OpenOptions.AutoCompact checks whether shouldCompact. Compact can also
+// be called directly at any time for an immediate, manual pass.
+const compactCheckInterval = 30 * time.Second
+
+// shouldCompact reports whether accumulated dead bytes warrant a
+// compaction pass, per the fraction/absolute thresholds configured in
+// OpenOptions.
+func (db *DB) shouldCompact() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	deadBytes := db.headDeadBytes + db.segDeadBytes
+	if deadBytes <= 0 {
+		return false
+	}
+
+	if db.compactMinDeadBytes > 0 && deadBytes >= db.compactMinDeadBytes {
+		return true
+	}
+
+	liveDataBytes := db.dataEndOffset - HeaderSize
+	if db.compactDeadFraction > 0 && liveDataBytes > 0 &&
+		float64(deadBytes)/float64(liveDataBytes) >= db.compactDeadFraction {
+		return true
+	}
+
+	return false
+}
+
+// runAutoCompact is the background goroutine started by OpenWithOptions
+// when OpenOptions.AutoCompact is set. It exits once db is closed or ctx
+// is done.
+func (db *DB) runAutoCompact(ctx context.Context) {
+	ticker := time.NewTicker(compactCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-db.closing:
+			return
+		case <-ticker.C:
+			if db.shouldCompact() {
+				_ = db.Compact(ctx)
+			}
+		}
+	}
+}
+
+// Compact rewrites the database file into a fresh, contiguous layout
+// that drops any record no longer referenced by db.index (e.g. ones
+// removed via Batch.Delete), reclaiming the space those tombstoned
+// records were holding in the append-only data section. Following
+// LevelDB's pattern, the new layout is written to a sibling temp file,
+// fsynced, and atomically renamed over the original before the file
+// handle and mmap are swapped under db.mu - so a crash mid-compaction
+// leaves the original file untouched. Compact refuses to run while any
+// Snapshot is open, since a snapshot's offsets are only guaranteed
+// stable in the file they were taken against. Once the head has been
+// compacted, Compact also merges the two oldest sealed segments (see
+// mergeSegments) if OpenOptions.SegmentSizeThreshold is in use.
+func (db *DB) Compact(ctx context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.backend != nil {
+		return ErrBackendReadOnly
+	}
+
+	if db.snapsList.Len() > 0 {
+		return ErrSnapshotsOpen
+	}
+
+	tmpPath := db.path + ".compact.tmp"
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	if _, err := tmpFile.Write(db.header.Encode()); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	newIndex := make(map[uint64]int64, len(db.index))
+	offset := int64(HeaderSize)
+
+	for hash, oldOffset := range db.index {
+		select {
+		case <-ctx.Done():
+			tmpFile.Close()
+			return ctx.Err()
+		default:
+		}
+
+		size, err := db.recordSize(oldOffset)
+		if err != nil {
+			tmpFile.Close()
+			return err
+		}
+
+		if _, err := tmpFile.WriteAt(db.mmap[oldOffset:oldOffset+size], offset); err != nil {
+			tmpFile.Close()
+			return err
+		}
+
+		newIndex[hash] = offset
+		offset += size
+	}
+
+	// The header written at the top of the loop still describes the old
+	// file (its IndexOffset/BloomOffset point past the smaller
+	// compacted data section, or into what is now the middle of it), so
+	// it must be corrected and re-written before this file is renamed
+	// into place - otherwise a crash between the rename and the next
+	// Close leaves an on-disk header lying about its own layout.
+	compactedHeader := *db.header
+	compactedHeader.IndexOffset = 0
+	compactedHeader.BloomOffset = 0
+	compactedHeader.BloomLength = 0
+	if _, err := tmpFile.WriteAt(compactedHeader.Encode(), 0); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, db.path); err != nil {
+		return err
+	}
+
+	oldFile := db.file
+
+	newFile, err := os.OpenFile(db.path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	db.file = newFile
+	db.index = newIndex
+	db.dataEndOffset = offset
+	db.headDeadBytes = 0
+	db.header = &compactedHeader // IndexOffset/BloomOffset rebuilt by the next Close
+
+	if err := db.remap(); err != nil {
+		return err
+	}
+
+	if err := oldFile.Close(); err != nil {
+		return err
+	}
+
+	return db.mergeSegments(ctx)
+}
+
+// mergeSegments merges the two oldest sealed segments into one, dropping
+// any record no longer live in either segment's in-memory index (e.g.
+// removed via Delete/DeleteByHash after sealing). Reads against the two
+// old segments' mmaps continue to work right up until they're swapped
+// out for the merged segment below, matching the read-during-compaction
+// behavior of the head compaction pass above.
+func (db *DB) mergeSegments(ctx context.Context) error {
+	if len(db.segments) < 2 {
+		return nil
+	}
+
+	a, b := db.segments[0], db.segments[1]
+
+	tmpPath := db.path + ".seg.merge.tmp"
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	header := NewHeaderWithCodec(db.dimension, db.defaultCodec)
+	if _, err := tmpFile.Write(header.Encode()); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	merged := make(map[uint64]int64, len(a.index)+len(b.index))
+	offset := int64(HeaderSize)
+
+	for _, seg := range []*segment{a, b} {
+		for hash, oldOffset := range seg.index {
+			select {
+			case <-ctx.Done():
+				tmpFile.Close()
+				return ctx.Err()
+			default:
+			}
+
+			size, err := recordSizeFrom(seg.mmap, seg.header, oldOffset)
+			if err != nil {
+				tmpFile.Close()
+				return err
+			}
+
+			if _, err := tmpFile.WriteAt(seg.mmap[oldOffset:oldOffset+size], offset); err != nil {
+				tmpFile.Close()
+				return err
+			}
+
+			merged[hash] = offset
+			offset += size
+		}
+	}
+
+	indexOffset, bloomOffset, bloomLength, err := writeIndexSectionTo(tmpFile, merged, offset)
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+	header.IndexOffset = uint64(indexOffset)
+	header.BloomOffset = uint64(bloomOffset)
+	header.BloomLength = uint64(bloomLength)
+	header.RecordCount = uint64(len(merged))
+	if _, err := tmpFile.WriteAt(header.Encode(), 0); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	id := newSegmentID()
+	mergedPath := segmentPath(db.path, id)
+	if err := os.Rename(tmpPath, mergedPath); err != nil {
+		return err
+	}
+
+	mergedSeg, err := openSegment(mergedPath, id, merged)
+	if err != nil {
+		return err
+	}
+
+	// a and b's dead bytes are now fully reclaimed - their live records
+	// were copied above and their dead ones dropped - but any other
+	// segment's dead bytes are untouched by this pass, so only subtract
+	// what was actually just reclaimed rather than zeroing the total.
+	db.segDeadBytes -= a.deadBytes + b.deadBytes
+
+	rest := append([]*segment{}, db.segments[2:]...)
+	db.segments = append([]*segment{mergedSeg}, rest...)
+
+	for _, old := range []*segment{a, b} {
+		if old.mmap != nil {
+			_ = syscall.Munmap(old.mmap)
+		}
+		_ = old.file.Close()
+		_ = os.Remove(old.path)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestDB_CompactReclaimsDeletedSpace(t *testing.T) {
+	tmpfile := "test_compact.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("keep", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Insert("drop", []float32{4, 5, 6}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	b := db.NewBatch()
+	b.Delete("drop")
+	if err := db.Write(b); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	if db.headDeadBytes == 0 {
+		t.Fatal("Expected headDeadBytes to be tracked after deleting a record")
+	}
+
+	if err := db.Compact(context.Background()); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	if db.headDeadBytes != 0 {
+		t.Errorf("Expected headDeadBytes to be reset after Compact, got %d", db.headDeadBytes)
+	}
+
+	if _, err := db.Get("keep"); err != nil {
+		t.Errorf("Expected 'keep' to survive compaction: %v", err)
+	}
+	if _, err := db.Get("drop"); err != ErrNotFound {
+		t.Errorf("Expected 'drop' to remain absent after compaction, got %v", err)
+	}
+}
+
+func TestDB_CompactRefusesWithOpenSnapshot(t *testing.T) {
+	tmpfile := "test_compact_snapshot.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	snap := db.NewSnapshot()
+	defer snap.Release()
+
+	if err := db.Compact(context.Background()); err != ErrSnapshotsOpen {
+		t.Fatalf("Expected ErrSnapshotsOpen, got %v", err)
+	}
+}
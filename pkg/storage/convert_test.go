@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConvert_MigratesRecordsByHash(t *testing.T) {
+	srcPath := "test_convert_v2.edb"
+	defer os.Remove(srcPath)
+	defer os.Remove(srcPath + ".v3")
+
+	src, err := OpenWithOptions(srcPath, 3, OpenOptions{CacheSize: 10})
+	if err != nil {
+		t.Fatalf("Failed to create source database: %v", err)
+	}
+	// Force the source file to look like a pre-v3 file so Convert has
+	// something to migrate from.
+	src.header.Version = 2
+
+	if err := src.Insert("hello", []float32{0.1, 0.2, 0.3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := src.Close(); err != nil {
+		t.Fatalf("Failed to close source database: %v", err)
+	}
+
+	dst, err := Convert(srcPath, 3, OpenOptions{CacheSize: 10, Compression: CodecSnappy})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	defer dst.Close()
+
+	if dst.header.Version != CurrentVersion {
+		t.Errorf("Expected converted DB to be version %d, got %d", CurrentVersion, dst.header.Version)
+	}
+
+	vector, err := dst.Get("hello")
+	if err != nil {
+		t.Fatalf("Failed to get migrated record: %v", err)
+	}
+	if vector[0] != 0.1 {
+		t.Errorf("Expected migrated vector[0] == 0.1, got %f", vector[0])
+	}
+}
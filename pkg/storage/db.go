@@ -1,19 +1,32 @@
 package storage
 
 import (
+	"container/list"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 )
 
+// nextNamespaceID hands out a fresh, process-wide unique namespace id to
+// DB instances that don't share an explicit OpenOptions.SharedCache, so
+// their private Cache never collides with another DB's namespace.
+var nextNamespaceID uint64
+
+func newNamespaceID() uint64 {
+	return atomic.AddUint64(&nextNamespaceID, 1)
+}
+
 var (
 	ErrNotFound          = errors.New("embedding not found")
 	ErrInvalidFormat     = errors.New("invalid file format")
 	ErrDimensionMismatch = errors.New("vector dimension mismatch")
+	ErrSnapshotsOpen     = errors.New("storage: cannot close DB with open snapshots")
 )
 
 type DB struct {
@@ -22,15 +35,96 @@ type DB struct {
 	mmap         []byte
 	header       *Header
 	index        map[uint64]int64 // hash -> offset (lightweight)
-	cache        *LRUCache         // hot vectors only
+	bloom        *bloomFilter      // Bloom filter over index's hashes, nil if index is empty
+	physCache    Cache      // physical cache, possibly shared with other DB instances
+	cache        Namespace  // this DB's namespace within physCache
+	cacheCapacity int       // capacity physCache was created with, for Stats
 	mu           sync.RWMutex
 	dimension    uint32
 	dataEndOffset int64 // Track end of data section (before index)
+	snapsList    *list.List // live *Snapshot values, oldest first
+	defaultCodec CodecKind  // codec for new records in freshly created v3 files
+
+	headDeadBytes       int64   // bytes occupied by head records no longer referenced by db.index
+	segDeadBytes        int64   // bytes occupied by sealed-segment records no longer referenced by any segment's index
+	compactDeadFraction float64
+	compactMinDeadBytes int64
+	closing             chan struct{}
+
+	ingestQueue       IngestQueue
+	ingestCancel      context.CancelFunc
+	ingestDone        sync.WaitGroup
+	ingestWorkerChans []chan Job // fed by the single runIngestDispatch reader of ingestQueue
+
+	segments             []*segment // sealed, immutable, oldest first
+	segmentSizeThreshold int64      // seal the head once its data section reaches this size (0 disables segmentation)
+	syncMode             SyncMode
+
+	backend Backend // set by OpenOptions.Backend; read-only, bypasses db.file/segments entirely
 }
 
+// SyncMode controls how often a DB fsyncs the underlying file, trading
+// durability against throughput.
+type SyncMode int
+
+const (
+	// SyncNone never fsyncs the head file except when sealHead seals it
+	// (Compact and sealed-segment deletes fsync the files they rewrite;
+	// Close does not fsync); a crash can lose writes made since the
+	// last of those. Fastest, and the default.
+	SyncNone SyncMode = iota
+	// SyncBatch fsyncs once per DB.Write/InsertBatch call, but not
+	// after each individual Insert.
+	SyncBatch
+	// SyncEach fsyncs after every Insert and every DB.Write/InsertBatch
+	// call. Slowest, strongest durability.
+	SyncEach
+)
+
 type OpenOptions struct {
-	LazyLoad  bool // Only load index, not vectors
-	CacheSize int  // LRU cache size (default 100)
+	LazyLoad    bool      // Only load index, not vectors
+	CacheSize   int       // LRU cache capacity (default 100)
+	Compression CodecKind // Default codec for newly created v3 files (default CodecRaw)
+	SharedCache Cache     // Optional: share one physical Cache across multiple DB instances
+
+	// AutoCompact starts a background goroutine that periodically calls
+	// Compact once CompactDeadFraction or CompactMinDeadBytes is
+	// exceeded. Compact can always be called manually regardless of
+	// this setting.
+	AutoCompact         bool
+	CompactDeadFraction float64 // trigger compaction once deadBytes/liveDataBytes reaches this (0 disables)
+	CompactMinDeadBytes int64   // trigger compaction once deadBytes reaches this many bytes (0 disables)
+
+	// Ingest, if set, routes IngestAsync through this queue instead of
+	// returning ErrNoIngestQueue. IngestWorkers controls how many
+	// goroutines drain it concurrently (default 1).
+	Ingest        IngestQueue
+	IngestWorkers int
+
+	// SyncMode controls how aggressively writes are fsynced, analogous
+	// to the write options of the LevelDB-family engines this package
+	// borrows from. SyncNone (the default) never fsyncs after a write;
+	// SyncBatch fsyncs once per DB.Write/InsertBatch call;
+	// SyncEach additionally fsyncs after every individual Insert.
+	SyncMode SyncMode
+
+	// SegmentSizeThreshold, if positive, turns the single growing file
+	// into an append-only set of immutable segments (Prometheus tsdb
+	// block style): once the mutable head segment's data section
+	// reaches this many bytes, it's sealed into a read-only sibling
+	// file (<path>.seg.<id>) and a fresh, empty head is started. Zero
+	// (the default) keeps the original single-growing-file behavior.
+	SegmentSizeThreshold int64
+
+	// Backend, if set, opens the database read-only against the given
+	// Backend instead of a local file at path: path is then not opened,
+	// read or written. readVector/recordSize/
+	// buildIndex all read through Backend.MmapRegion when it's
+	// supported, falling back to Backend.ReadAt (see readVectorAt,
+	// recordSizeAt, buildIndexAt) otherwise. Insert, Write, Delete,
+	// Compact and segmentation are all local-file features and return
+	// ErrBackendReadOnly against a Backend-opened DB.
+	Backend Backend
 }
 
 func Open(path string, dimension uint32) (*DB, error) {
@@ -45,21 +139,78 @@ func OpenWithOptions(path string, dimension uint32, opts OpenOptions) (*DB, erro
 		opts.CacheSize = 100
 	}
 
+	physCache := opts.SharedCache
+	if physCache == nil {
+		physCache = NewCache(opts.CacheSize)
+	}
+
 	db := &DB{
-		path:      path,
-		index:     make(map[uint64]int64),
-		cache:     NewLRUCache(opts.CacheSize),
-		dimension: dimension,
+		path:                path,
+		index:               make(map[uint64]int64),
+		physCache:           physCache,
+		cache:               physCache.GetNamespace(newNamespaceID()),
+		cacheCapacity:       opts.CacheSize,
+		dimension:           dimension,
+		snapsList:           list.New(),
+		defaultCodec:        opts.Compression,
+		compactDeadFraction: opts.CompactDeadFraction,
+		compactMinDeadBytes: opts.CompactMinDeadBytes,
+		closing:             make(chan struct{}),
+		ingestQueue:         opts.Ingest,
+		segmentSizeThreshold: opts.SegmentSizeThreshold,
+		syncMode:            opts.SyncMode,
 	}
 
-	_, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return db.create()
-	} else if err != nil {
+	var err error
+	if opts.Backend != nil {
+		db, err = db.openFromBackend(opts.Backend)
+	} else {
+		_, statErr := os.Stat(path)
+		if os.IsNotExist(statErr) {
+			db, err = db.create()
+		} else if statErr != nil {
+			return nil, statErr
+		} else {
+			db, err = db.open()
+		}
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	return db.open()
+	if opts.AutoCompact && db.backend == nil {
+		go db.runAutoCompact(context.Background())
+	}
+
+	if db.ingestQueue != nil {
+		workers := opts.IngestWorkers
+		if workers <= 0 {
+			workers = 1
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		db.ingestCancel = cancel
+
+		db.ingestWorkerChans = make([]chan Job, workers)
+		for i := range db.ingestWorkerChans {
+			db.ingestWorkerChans[i] = make(chan Job, ingestCoalesceSize)
+
+			ch := db.ingestWorkerChans[i]
+			db.ingestDone.Add(1)
+			go func() {
+				defer db.ingestDone.Done()
+				db.runIngestWorker(ctx, ch)
+			}()
+		}
+
+		db.ingestDone.Add(1)
+		go func() {
+			defer db.ingestDone.Done()
+			db.runIngestDispatch(ctx)
+		}()
+	}
+
+	return db, nil
 }
 
 func (db *DB) create() (*DB, error) {
@@ -69,7 +220,7 @@ func (db *DB) create() (*DB, error) {
 	}
 	db.file = f
 
-	header := NewHeader(db.dimension)
+	header := NewHeaderWithCodec(db.dimension, db.defaultCodec)
 	headerBytes := header.Encode()
 	if _, err := f.Write(headerBytes); err != nil {
 		f.Close()
@@ -131,6 +282,7 @@ func (db *DB) open() (*DB, error) {
 			db.Close()
 			return nil, err
 		}
+		db.bloom = loadOrRebuildBloom(db.mmap, db.header, db.index)
 
 		// If file has index section, track where data ends
 		if db.header.Version >= 2 && db.header.IndexOffset > 0 {
@@ -142,31 +294,137 @@ func (db *DB) open() (*DB, error) {
 		db.dataEndOffset = HeaderSize
 	}
 
+	if err := db.loadSegments(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
 
-func (db *DB) buildIndex() error {
-	// Check if file uses new format (version 2+) with separate index section
-	if db.header.Version >= 2 && db.header.IndexOffset > 0 {
-		return db.buildIndexFromSection()
+// openFromBackend opens db read-only against backend instead of a local
+// file at db.path, per OpenOptions.Backend. It prefers backend's
+// MmapRegion (same fast path db.open uses for a local file) and falls
+// back to reading through Backend.ReadAt (via buildIndexAt,
+// readVectorAt, recordSizeAt) when MmapRegion returns any error -
+// ErrMmapUnsupported, or e.g. a LocalBackend opened O_RDONLY, which
+// can't be mapped with PROT_WRITE. db.file and db.segments are left unset; write
+// paths check db.backend and refuse with ErrBackendReadOnly.
+func (db *DB) openFromBackend(backend Backend) (*DB, error) {
+	db.backend = backend
+
+	size, err := backend.Size()
+	if err != nil {
+		return nil, err
+	}
+	if size < HeaderSize {
+		return nil, ErrInvalidFormat
+	}
+
+	headerBytes := make([]byte, HeaderSize)
+	if _, err := backend.ReadAt(headerBytes, 0); err != nil {
+		return nil, err
+	}
+
+	header := DecodeHeader(headerBytes)
+	if string(header.Magic[:]) != MagicBytes {
+		return nil, ErrInvalidFormat
+	}
+	if header.Dimension != db.dimension {
+		return nil, fmt.Errorf("%w: expected %d, got %d",
+			ErrDimensionMismatch, db.dimension, header.Dimension)
+	}
+	db.header = header
+
+	if mmap, mmapErr := backend.MmapRegion(0, size); mmapErr == nil {
+		db.mmap = mmap
+		if err := db.buildIndex(); err != nil {
+			return nil, err
+		}
+		db.bloom = loadOrRebuildBloom(db.mmap, db.header, db.index)
+	} else {
+		index, err := buildIndexAt(backend, header, size)
+		if err != nil {
+			return nil, err
+		}
+		db.index = index
+
+		bloom, err := loadOrRebuildBloomAt(backend, header, index)
+		if err != nil {
+			return nil, err
+		}
+		db.bloom = bloom
 	}
 
-	// Legacy format (version 1) - scan entire file
-	return db.buildIndexLegacy()
+	if header.Version >= 2 && header.IndexOffset > 0 {
+		db.dataEndOffset = int64(header.IndexOffset)
+	} else {
+		db.dataEndOffset = size
+	}
+
+	return db, nil
 }
 
-// buildIndexFromSection reads index from dedicated index section (fast!)
-func (db *DB) buildIndexFromSection() error {
-	indexOffset := int64(db.header.IndexOffset)
-	fileSize := int64(len(db.mmap))
+// syncEach fsyncs db.file if OpenOptions.SyncMode is SyncEach. Called by
+// Insert/insertRaw after every individual write.
+func (db *DB) syncEach() error {
+	if db.syncMode == SyncEach {
+		return db.file.Sync()
+	}
+	return nil
+}
+
+// syncBatch fsyncs db.file if OpenOptions.SyncMode requests per-batch
+// durability (SyncBatch or SyncEach). Called once at the end of
+// DB.Write/InsertBatch, regardless of how many records it wrote.
+func (db *DB) syncBatch() error {
+	if db.syncMode == SyncBatch || db.syncMode == SyncEach {
+		return db.file.Sync()
+	}
+	return nil
+}
 
-	// Index section is from IndexOffset to end of file
-	// Layout: Header -> Data Section -> Index Section
-	indexSize := fileSize - indexOffset
+func (db *DB) buildIndex() error {
+	index, err := buildIndexFrom(db.mmap, db.header)
+	if err != nil {
+		return err
+	}
+	db.index = index
+	return nil
+}
+
+// buildIndexFrom rebuilds a hash->offset index for an mmap/header pair,
+// dispatching to the fast index-section path (version 2+, once one has
+// been written) or a full legacy scan otherwise. Shared by the head
+// segment and by loadSegments when rediscovering sealed segments on
+// Open.
+func buildIndexFrom(mmap []byte, header *Header) (map[uint64]int64, error) {
+	if header.Version >= 2 && header.IndexOffset > 0 {
+		return buildIndexFromSectionOf(mmap, header)
+	}
+	return buildIndexLegacyOf(mmap, header)
+}
+
+// buildIndexFromSectionOf reads index entries from the dedicated index
+// section (fast!).
+func buildIndexFromSectionOf(mmap []byte, header *Header) (map[uint64]int64, error) {
+	indexOffset := int64(header.IndexOffset)
+	fileSize := int64(len(mmap))
+
+	// Index section is from IndexOffset up to the Bloom filter block (if
+	// any was written) or, for a file predating this package writing
+	// one, the end of the file.
+	// Layout: Header -> Data Section -> Index Section -> Bloom filter
+	indexEnd := fileSize
+	if header.BloomOffset > 0 {
+		indexEnd = int64(header.BloomOffset)
+	}
+	indexSize := indexEnd - indexOffset
 	numEntries := indexSize / IndexEntrySize
 
+	index := make(map[uint64]int64, numEntries)
 	if numEntries == 0 {
-		return nil
+		return index, nil
 	}
 
 	// Read all index entries at once (much faster than scanning data)
@@ -176,33 +434,54 @@ func (db *DB) buildIndexFromSection() error {
 			break
 		}
 
-		entry := DecodeIndexEntry(db.mmap[offset : offset+IndexEntrySize])
-		db.index[entry.Hash] = entry.Offset
+		entry := DecodeIndexEntry(mmap[offset : offset+IndexEntrySize])
+		index[entry.Hash] = entry.Offset
 	}
 
-	return nil
+	return index, nil
 }
 
-// buildIndexLegacy scans entire file (slow, for backward compatibility)
-func (db *DB) buildIndexLegacy() error {
+// buildIndexLegacyOf scans the entire data section (slow, for backward
+// compatibility with files that have no index section yet).
+func buildIndexLegacyOf(mmap []byte, header *Header) (map[uint64]int64, error) {
+	index := make(map[uint64]int64)
 	offset := int64(HeaderSize)
-	fileSize := int64(len(db.mmap))
+	fileSize := int64(len(mmap))
 
 	for offset < fileSize {
+		if header.Version >= 3 {
+			if offset+RecordHeaderV3Size > fileSize {
+				break
+			}
+
+			hash := binary.LittleEndian.Uint64(mmap[offset : offset+8])
+			codec := mmap[offset+8]
+			compressedLen := binary.LittleEndian.Uint32(mmap[offset+9 : offset+13])
+
+			if codec&tombstoneCodecBit == 0 {
+				index[hash] = offset
+			}
+			offset += int64(RecordHeaderV3Size) + int64(compressedLen)
+			continue
+		}
+
 		if offset+RecordMetaSize > fileSize {
 			break
 		}
 
-		hash := binary.LittleEndian.Uint64(db.mmap[offset : offset+8])
-		dim := binary.LittleEndian.Uint32(db.mmap[offset+8 : offset+12])
+		hash := binary.LittleEndian.Uint64(mmap[offset : offset+8])
+		rawDim := binary.LittleEndian.Uint32(mmap[offset+8 : offset+12])
+		dim := rawDim &^ tombstoneDimBit
 
-		db.index[hash] = offset
+		if rawDim&tombstoneDimBit == 0 {
+			index[hash] = offset
+		}
 
 		recordSize := RecordMetaSize + int64(dim)*4
 		offset += recordSize
 	}
 
-	return nil
+	return index, nil
 }
 
 func (db *DB) Insert(text string, vector []float32) error {
@@ -214,21 +493,19 @@ func (db *DB) Insert(text string, vector []float32) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if db.backend != nil {
+		return ErrBackendReadOnly
+	}
+
 	hash := HashText(text)
 
-	if _, exists := db.index[hash]; exists {
+	if db.hashExists(hash) {
 		return nil
 	}
 
-	recordSize := RecordMetaSize + len(vector)*4
-	buf := make([]byte, recordSize)
-
-	binary.LittleEndian.PutUint64(buf[0:8], hash)
-	binary.LittleEndian.PutUint32(buf[8:12], uint32(len(vector)))
-
-	for i, v := range vector {
-		bits := math.Float32bits(v)
-		binary.LittleEndian.PutUint32(buf[RecordMetaSize+i*4:], bits)
+	buf, err := db.encodeRecord(hash, vector)
+	if err != nil {
+		return err
 	}
 
 	// Write to end of data section (before index section if it exists)
@@ -239,10 +516,19 @@ func (db *DB) Insert(text string, vector []float32) error {
 	}
 
 	db.index[hash] = offset
+	db.bloomAdd(hash)
 	db.header.RecordCount++
 
 	// Update data end offset for next insert
-	db.dataEndOffset = offset + int64(recordSize)
+	db.dataEndOffset = offset + int64(len(buf))
+
+	if err := db.syncEach(); err != nil {
+		return err
+	}
+
+	if db.segmentSizeThreshold > 0 && db.dataEndOffset-HeaderSize >= db.segmentSizeThreshold {
+		return db.sealHead()
+	}
 
 	if err := db.remap(); err != nil {
 		return err
@@ -251,77 +537,271 @@ func (db *DB) Insert(text string, vector []float32) error {
 	return nil
 }
 
-func (db *DB) Get(text string) ([]float32, error) {
-	hash := HashText(text)
+// insertRaw writes a record under an already-computed hash, bypassing
+// HashText. Used by Convert, where only the hash (not the source text)
+// survives migration from an older file format.
+func (db *DB) insertRaw(hash uint64, vector []float32) error {
+	if len(vector) != int(db.dimension) {
+		return fmt.Errorf("%w: expected %d, got %d",
+			ErrDimensionMismatch, db.dimension, len(vector))
+	}
 
-	// Check LRU cache first (no lock needed)
-	if cached, ok := db.cache.Get(hash); ok {
-		return cached, nil
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.backend != nil {
+		return ErrBackendReadOnly
 	}
 
-	// Cache miss - read from mmap
-	db.mu.RLock()
-	offset, exists := db.index[hash]
-	db.mu.RUnlock()
+	if db.hashExists(hash) {
+		return nil
+	}
 
-	if !exists {
-		return nil, ErrNotFound
+	buf, err := db.encodeRecord(hash, vector)
+	if err != nil {
+		return err
 	}
 
-	// Read vector from mmap (lock held during read)
-	db.mu.RLock()
-	vector, err := db.readVector(offset)
-	db.mu.RUnlock()
+	offset := db.dataEndOffset
+	if _, err := db.file.WriteAt(buf, offset); err != nil {
+		return err
+	}
+
+	db.index[hash] = offset
+	db.bloomAdd(hash)
+	db.header.RecordCount++
+	db.dataEndOffset = offset + int64(len(buf))
+
+	if err := db.syncEach(); err != nil {
+		return err
+	}
+
+	return db.remap()
+}
+
+// encodeRecord serializes hash/vector into the on-disk record layout
+// appropriate for db.header.Version, so Insert, Batch.Write and
+// insertRaw all agree on the bytes written. Version 3 files use a
+// variable-length, per-record-codec header (RecordHeaderV3Size);
+// earlier versions use the fixed RecordMetaSize layout.
+func (db *DB) encodeRecord(hash uint64, vector []float32) ([]byte, error) {
+	if db.header.Version >= 3 {
+		encoded, uncompressedLen, err := encodeVector(CodecKind(db.header.Codec), vector)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, int(RecordHeaderV3Size)+len(encoded))
+		binary.LittleEndian.PutUint64(buf[0:8], hash)
+		buf[8] = db.header.Codec
+		binary.LittleEndian.PutUint32(buf[9:13], uint32(len(encoded)))
+		binary.LittleEndian.PutUint32(buf[13:17], uncompressedLen)
+		copy(buf[RecordHeaderV3Size:], encoded)
+		return buf, nil
+	}
+
+	buf := make([]byte, RecordMetaSize+len(vector)*4)
+	binary.LittleEndian.PutUint64(buf[0:8], hash)
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(len(vector)))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[RecordMetaSize+i*4:], math.Float32bits(v))
+	}
+	return buf, nil
+}
+
+// Convert migrates the database at path (version 2 or earlier) to
+// format version 3, writing the result to a sibling file at path+".v3"
+// using opts (notably opts.Compression) and returning it opened. The
+// original file is left untouched; only hash and vector bytes survive
+// the migration; source text cannot be recovered from a v2 file, so the
+// new DB must be queried by re-hashing the same text the caller used
+// originally.
+func Convert(path string, dimension uint32, opts OpenOptions) (*DB, error) {
+	src, err := OpenWithOptions(path, dimension, OpenOptions{LazyLoad: true, CacheSize: 1})
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	if src.header.Version >= CurrentVersion {
+		return nil, fmt.Errorf("storage: %s is already format version %d", path, src.header.Version)
+	}
 
+	dst, err := OpenWithOptions(path+".v3", dimension, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// Add to cache for next time
-	db.cache.Put(hash, vector)
+	src.mu.RLock()
+	offsets := make(map[uint64]int64, len(src.index))
+	for hash, offset := range src.index {
+		offsets[hash] = offset
+	}
+	src.mu.RUnlock()
 
-	return vector, nil
+	for hash, offset := range offsets {
+		vector, err := src.readVector(offset)
+		if err != nil {
+			dst.Close()
+			return nil, err
+		}
+		if err := dst.insertRaw(hash, vector); err != nil {
+			dst.Close()
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+func (db *DB) Get(text string) ([]float32, error) {
+	hash := HashText(text)
+
+	var readErr error
+	handle, hit := db.cache.Get(hash, func() []float32 {
+		db.mu.RLock()
+		if db.bloom == nil || db.bloom.MayContain(hash) {
+			if offset, exists := db.index[hash]; exists {
+				vector, err := db.readVector(offset)
+				db.mu.RUnlock()
+				if err != nil {
+					readErr = err
+					return nil
+				}
+				return vector
+			}
+		}
+
+		// Not in the mutable head; check sealed segments, newest first,
+		// since a hash can only live in one place at a time but a
+		// not-yet-merged sealed segment is the next most likely spot.
+		// Each segment's own Bloom filter lets most segments be skipped
+		// without even touching their index map.
+		for i := len(db.segments) - 1; i >= 0; i-- {
+			seg := db.segments[i]
+			if seg.bloom != nil && !seg.bloom.MayContain(hash) {
+				continue
+			}
+			if offset, exists := seg.index[hash]; exists {
+				vector, err := readVectorFrom(seg.mmap, seg.header, offset)
+				db.mu.RUnlock()
+				if err != nil {
+					readErr = err
+					return nil
+				}
+				return vector
+			}
+		}
+
+		db.mu.RUnlock()
+		readErr = ErrNotFound
+		return nil
+	})
+
+	if !hit && readErr != nil {
+		// Don't let a miss or read error sit in the cache as a poisoned
+		// nil entry for the next lookup.
+		handle.Release()
+		db.cache.Delete(hash, nil)
+		return nil, readErr
+	}
+
+	defer handle.Release()
+	return handle.Vector(), nil
 }
 
 func (db *DB) readVector(offset int64) ([]float32, error) {
-	if db.mmap == nil {
-		return nil, ErrNotFound
+	if db.mmap != nil {
+		return readVectorFrom(db.mmap, db.header, offset)
+	}
+	if db.backend != nil {
+		return readVectorAt(db.backend, db.header, offset)
+	}
+	return nil, ErrNotFound
+}
+
+// readVectorFrom decodes the vector stored at offset within mmap, whose
+// layout is determined by header.Version. It's a free function (rather
+// than a DB method) so both the head segment (db.mmap/db.header) and
+// sealed segments (segment.mmap/segment.header) can share the same
+// decode logic.
+func readVectorFrom(mmap []byte, header *Header, offset int64) ([]float32, error) {
+	if header.Version >= 3 {
+		codec := CodecKind(mmap[offset+8] &^ tombstoneCodecBit)
+		compressedLen := binary.LittleEndian.Uint32(mmap[offset+9 : offset+13])
+		uncompressedLen := binary.LittleEndian.Uint32(mmap[offset+13 : offset+17])
+
+		payloadOffset := offset + int64(RecordHeaderV3Size)
+		encoded := mmap[payloadOffset : payloadOffset+int64(compressedLen)]
+
+		return decodeVector(codec, uncompressedLen/4, encoded, uncompressedLen)
 	}
 
-	dim := binary.LittleEndian.Uint32(db.mmap[offset+8 : offset+12])
+	dim := binary.LittleEndian.Uint32(mmap[offset+8:offset+12]) &^ tombstoneDimBit
 
 	vector := make([]float32, dim)
 	vectorOffset := offset + RecordMetaSize
 
 	for i := range vector {
-		bits := binary.LittleEndian.Uint32(db.mmap[vectorOffset+int64(i)*4:])
+		bits := binary.LittleEndian.Uint32(mmap[vectorOffset+int64(i)*4:])
 		vector[i] = math.Float32frombits(bits)
 	}
 
 	return vector, nil
 }
 
+// recordSize returns the total on-disk size (header + payload) of the
+// record at offset, without decoding its vector. Used by Compact to
+// copy live records byte-for-byte and by Batch.Write to track deadBytes
+// when a record is deleted.
+func (db *DB) recordSize(offset int64) (int64, error) {
+	if db.mmap != nil {
+		return recordSizeFrom(db.mmap, db.header, offset)
+	}
+	if db.backend != nil {
+		return recordSizeAt(db.backend, db.header, offset)
+	}
+	return 0, ErrNotFound
+}
+
+// recordSizeFrom is the segment-agnostic counterpart to readVectorFrom,
+// used by both the head segment and sealed segments.
+func recordSizeFrom(mmap []byte, header *Header, offset int64) (int64, error) {
+	if header.Version >= 3 {
+		compressedLen := binary.LittleEndian.Uint32(mmap[offset+9 : offset+13])
+		return int64(RecordHeaderV3Size) + int64(compressedLen), nil
+	}
+
+	dim := binary.LittleEndian.Uint32(mmap[offset+8:offset+12]) &^ tombstoneDimBit
+	return RecordMetaSize + int64(dim)*4, nil
+}
+
 func (db *DB) FindSimilar(vector []float32, threshold float32) ([]float32, float32, error) {
 	if len(vector) != int(db.dimension) {
 		return nil, 0, ErrDimensionMismatch
 	}
 
+	// Held for the whole scan, head and sealed segments alike: a sealed
+	// segment is only immutable while it's still referenced from
+	// db.segments - mergeSegments (compact.go) drops old segments and
+	// munmaps them under db.mu.Lock(), so a reader that let go of db.mu
+	// before touching seg.mmap could fault on a segment a concurrent
+	// Compact had already torn down.
 	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	offsets := make([]int64, 0, len(db.index))
 	for _, offset := range db.index {
 		offsets = append(offsets, offset)
 	}
-	db.mu.RUnlock()
+	segs := db.segments
 
 	var bestVector []float32
 	var bestScore float32 = -1
 
-	// O(n) linear scan - will add HNSW in Phase 2
+	// O(n) linear scan of the mutable head - will add HNSW in Phase 2
 	for _, offset := range offsets {
-		db.mu.RLock()
 		cached, err := db.readVector(offset)
-		db.mu.RUnlock()
-
 		if err != nil {
 			continue
 		}
@@ -333,6 +813,41 @@ func (db *DB) FindSimilar(vector []float32, threshold float32) ([]float32, float
 		}
 	}
 
+	// Fan out one goroutine per segment and merge the per-segment best
+	// at the end; still under the db.mu.RLock acquired above.
+	type segBest struct {
+		vector []float32
+		score  float32
+	}
+	results := make(chan segBest, len(segs))
+	var wg sync.WaitGroup
+	for _, seg := range segs {
+		wg.Add(1)
+		go func(seg *segment) {
+			defer wg.Done()
+			best := segBest{score: -1}
+			for _, offset := range seg.index {
+				cached, err := readVectorFrom(seg.mmap, seg.header, offset)
+				if err != nil {
+					continue
+				}
+				if score := cosineSimilarity(vector, cached); score > best.score {
+					best = segBest{vector: cached, score: score}
+				}
+			}
+			results <- best
+		}(seg)
+	}
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		if r.score > bestScore {
+			bestScore = r.score
+			bestVector = r.vector
+		}
+	}
+
 	if bestScore >= threshold {
 		return bestVector, bestScore, nil
 	}
@@ -391,22 +906,53 @@ func (db *DB) Stats() map[string]interface{} {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	stat, _ := db.file.Stat()
+	var fileSize int64
+	if db.backend != nil {
+		fileSize, _ = db.backend.Size()
+	} else if stat, err := db.file.Stat(); err == nil {
+		fileSize = stat.Size()
+	}
+
+	records := len(db.index)
+	for _, seg := range db.segments {
+		records += len(seg.index)
+	}
 
 	return map[string]interface{}{
-		"records":     len(db.index),
-		"dimension":   db.dimension,
-		"file_size":   stat.Size(),
-		"index_size":  len(db.index),
-		"cache_size":  db.cache.Len(),
-		"cache_capacity": db.cache.capacity,
+		"records":        records,
+		"dimension":      db.dimension,
+		"file_size":      fileSize,
+		"index_size":     len(db.index),
+		"segments":       len(db.segments),
+		"cache_size":     db.physCache.Size(),
+		"cache_alive":    db.physCache.NumObjects(),
+		"cache_capacity": db.cacheCapacity,
 	}
 }
 
 func (db *DB) Close() error {
+	if db.ingestCancel != nil {
+		db.ingestCancel()
+		db.ingestDone.Wait()
+	}
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if db.snapsList.Len() > 0 {
+		return ErrSnapshotsOpen
+	}
+
+	if db.backend != nil {
+		if db.mmap != nil {
+			if err := syscall.Munmap(db.mmap); err != nil {
+				return err
+			}
+		}
+		close(db.closing)
+		return db.backend.Close()
+	}
+
 	// Write index section if we have records (new format)
 	if len(db.index) > 0 && db.header.Version >= 2 {
 		if err := db.writeIndexSection(); err != nil {
@@ -426,41 +972,68 @@ func (db *DB) Close() error {
 		}
 	}
 
+	for _, seg := range db.segments {
+		if seg.mmap != nil {
+			_ = syscall.Munmap(seg.mmap)
+		}
+		_ = seg.file.Close()
+	}
+
+	close(db.closing)
+
 	return db.file.Close()
 }
 
-// writeIndexSection writes the index section at the end of the data
+// writeIndexSection writes the index section, followed by a Bloom
+// filter block, at the end of the data section.
 func (db *DB) writeIndexSection() error {
-	// Index section starts right after data section
-	indexOffset := db.dataEndOffset
-
-	// Collect all index entries
-	entries := make([]IndexEntry, 0, len(db.index))
-	for hash, offset := range db.index {
-		entries = append(entries, IndexEntry{
-			Hash:   hash,
-			Offset: offset,
-		})
-	}
-
-	// Write index entries starting at dataEndOffset
-	currentOffset := indexOffset
-	for _, entry := range entries {
-		entryBytes := EncodeIndexEntry(entry)
-		if _, err := db.file.WriteAt(entryBytes, currentOffset); err != nil {
-			return err
-		}
-		currentOffset += IndexEntrySize
-	}
-
-	// Truncate file to remove any old index section
-	if err := db.file.Truncate(currentOffset); err != nil {
+	indexOffset, bloomOffset, bloomLength, err := writeIndexSectionTo(db.file, db.index, db.dataEndOffset)
+	if err != nil {
 		return err
 	}
 
-	// Update header with index location
+	// Update header with index/filter location
 	db.header.IndexOffset = uint64(indexOffset)
+	db.header.BloomOffset = uint64(bloomOffset)
+	db.header.BloomLength = uint64(bloomLength)
 	// DataOffset stays at HeaderSize (data starts right after header)
 
 	return nil
 }
+
+// writeIndexSectionTo writes index as an index section starting at
+// dataEndOffset in f, followed immediately by a Bloom filter built over
+// every hash in index, truncating f to drop any old index/filter section
+// past it. It returns the index section's offset (== dataEndOffset) and
+// the Bloom block's offset and byte length (both 0 if index is empty, so
+// no filter was written). It's a free function so sealHead/mergeSegments
+// can write a sealed segment's own index+filter section the same way the
+// head's is written.
+func writeIndexSectionTo(f *os.File, index map[uint64]int64, dataEndOffset int64) (indexOffset, bloomOffset, bloomLength int64, err error) {
+	currentOffset := dataEndOffset
+	for hash, offset := range index {
+		entryBytes := EncodeIndexEntry(IndexEntry{Hash: hash, Offset: offset})
+		if _, err := f.WriteAt(entryBytes, currentOffset); err != nil {
+			return 0, 0, 0, err
+		}
+		currentOffset += IndexEntrySize
+	}
+
+	var filterOffset, filterLength int64
+	if len(index) > 0 {
+		filter := buildBloomFilter(index)
+		encoded := filter.encode()
+		if _, err := f.WriteAt(encoded, currentOffset); err != nil {
+			return 0, 0, 0, err
+		}
+		filterOffset = currentOffset
+		filterLength = int64(len(encoded))
+		currentOffset += filterLength
+	}
+
+	if err := f.Truncate(currentOffset); err != nil {
+		return 0, 0, 0, err
+	}
+
+	return dataEndOffset, filterOffset, filterLength, nil
+}
@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -262,6 +264,61 @@ func TestDB_FindSimilar(t *testing.T) {
 	t.Logf("Result: %v", result)
 }
 
+func TestDB_FindSimilarSurvivesConcurrentCompact(t *testing.T) {
+	tmpfile := "test_similar_concurrent_compact.edb"
+	defer removeWithSegments(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 20; i++ {
+		text := "seed-" + string(rune('a'+i))
+		if err := db.Insert(text, []float32{float32(i), 0, 0}); err != nil {
+			t.Fatalf("Failed to insert %q: %v", text, err)
+		}
+	}
+	if len(db.segments) < 2 {
+		t.Fatalf("Expected at least 2 sealed segments, got %d", len(db.segments))
+	}
+
+	// A FindSimilar/SearchTopK goroutine reading sealed segments must
+	// never race a concurrent Compact merging and munmapping them out
+	// from under it.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, _, err := db.FindSimilar([]float32{1, 0, 0}, 0); err != nil && err != ErrNotFound {
+					t.Errorf("FindSimilar failed: %v", err)
+				}
+				if _, err := db.SearchTopK([]float32{1, 0, 0}, 3, 0); err != nil {
+					t.Errorf("SearchTopK failed: %v", err)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := db.Compact(context.Background()); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
 func BenchmarkInsert(b *testing.B) {
 	tmpfile := "bench_insert.edb"
 	defer os.Remove(tmpfile)
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+)
+
+// tombstoneDimBit flags a v1/v2 record as deleted by setting the high bit
+// of its Dimension field, which no real dimension ever sets. It lets
+// buildIndexLegacyOf recognize and skip deleted records when rebuilding
+// the index from a raw byte scan, e.g. for a v1 file or one reopened
+// before its index section was last persisted.
+const tombstoneDimBit = uint32(1) << 31
+
+// tombstoneCodecBit is the v3 equivalent of tombstoneDimBit, flagging a
+// record as deleted via the high bit of its Codec byte (only the low
+// bits 0-3 are ever legitimately used by CodecKind).
+const tombstoneCodecBit = byte(1) << 7
+
+// Delete removes the record for text, identified by its hash, so that
+// subsequent Get/FindSimilar calls no longer see it. The underlying
+// bytes are tombstoned in place rather than erased immediately; Vacuum
+// (or a background Compact pass) reclaims the space.
+func (db *DB) Delete(text string) error {
+	return db.DeleteByHash(HashText(text))
+}
+
+// DeleteByHash is the hash-keyed equivalent of Delete, for callers that
+// already have the hash (e.g. from a prior Get/FindSimilar result).
+func (db *DB) DeleteByHash(hash uint64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.backend != nil {
+		return ErrBackendReadOnly
+	}
+
+	if offset, exists := db.index[hash]; exists {
+		size, err := db.recordSize(offset)
+		if err != nil {
+			return err
+		}
+
+		if err := db.tombstoneRecord(offset); err != nil {
+			return err
+		}
+
+		delete(db.index, hash)
+		db.headDeadBytes += size
+		db.cache.Delete(hash, nil)
+		return nil
+	}
+
+	// Sealed segments are immutable data files, so there's no tombstone
+	// byte to flip in place; instead the hash is dropped from the
+	// segment's index and its on-disk index section is rewritten to
+	// match, so the deletion survives a reopen instead of being
+	// rediscovered by loadSegments. mergeSegments also treats it as dead
+	// and skips it the next time that segment gets folded into another.
+	for i := len(db.segments) - 1; i >= 0; i-- {
+		seg := db.segments[i]
+		offset, exists := seg.index[hash]
+		if !exists {
+			continue
+		}
+
+		size, err := recordSizeFrom(seg.mmap, seg.header, offset)
+		if err != nil {
+			return err
+		}
+
+		delete(seg.index, hash)
+		seg.deadBytes += size
+		db.segDeadBytes += size
+
+		if err := db.persistSegmentDelete(seg); err != nil {
+			return err
+		}
+
+		db.cache.Delete(hash, nil)
+		return nil
+	}
+
+	return ErrNotFound
+}
+
+// tombstoneRecord flags the record at offset as deleted, both in the
+// mmap and on disk, so a future buildIndexLegacyOf scan skips it even if
+// the in-memory index is lost (e.g. a crash before the next Close).
+func (db *DB) tombstoneRecord(offset int64) error {
+	if db.header.Version >= 3 {
+		codec := db.mmap[offset+8] | tombstoneCodecBit
+		db.mmap[offset+8] = codec
+		_, err := db.file.WriteAt([]byte{codec}, offset+8)
+		return err
+	}
+
+	dim := binary.LittleEndian.Uint32(db.mmap[offset+8:offset+12]) | tombstoneDimBit
+	binary.LittleEndian.PutUint32(db.mmap[offset+8:offset+12], dim)
+
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, dim)
+	_, err := db.file.WriteAt(buf, offset+8)
+	return err
+}
+
+// Vacuum reclaims space held by deleted records by running an immediate
+// Compact pass. It's a named, discoverable entry point for callers who
+// don't otherwise need OpenOptions.AutoCompact or a context.
+func (db *DB) Vacuum() error {
+	return db.Compact(context.Background())
+}
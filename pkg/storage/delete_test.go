@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDB_DeleteThenGetReturnsNotFound(t *testing.T) {
+	tmpfile := "test_delete.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("hello", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if err := db.Delete("hello"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if _, err := db.Get("hello"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+
+	if err := db.Delete("hello"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound deleting an already-deleted record, got %v", err)
+	}
+}
+
+func TestDB_DeleteByHash(t *testing.T) {
+	tmpfile := "test_delete_hash.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("hello", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if err := db.DeleteByHash(HashText("hello")); err != nil {
+		t.Fatalf("DeleteByHash failed: %v", err)
+	}
+
+	if _, err := db.Get("hello"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after DeleteByHash, got %v", err)
+	}
+}
+
+func TestDB_DeleteThenReopenSkipsTombstone(t *testing.T) {
+	tmpfile := "test_delete_reopen.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	if err := db.Insert("keep", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Insert("drop", []float32{4, 5, 6}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Delete("drop"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Force a legacy-style reopen, as after a crash: db is not closed
+	// first, so no index section has been persisted yet and the on-disk
+	// header's IndexOffset is still 0. The next Open has to rebuild the
+	// index from a raw scan via buildIndexLegacyOf, which must honor the
+	// tombstone Delete just wrote. (Close would write an index section
+	// and point IndexOffset at it, bypassing the raw scan.)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}()
+
+	reopened, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.Get("keep"); err != nil {
+		t.Errorf("Expected 'keep' to survive reopen: %v", err)
+	}
+	if _, err := reopened.Get("drop"); err != ErrNotFound {
+		t.Errorf("Expected 'drop' to remain absent after reopen, got %v", err)
+	}
+}
+
+func TestDB_DeleteFromSealedSegmentSurvivesReopen(t *testing.T) {
+	tmpfile := "test_delete_segment.edb"
+	defer removeWithSegments(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	if err := db.Insert("drop", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if len(db.segments) == 0 {
+		t.Fatal("Expected the tiny threshold to have sealed a segment")
+	}
+
+	if err := db.DeleteByHash(HashText("drop")); err != nil {
+		t.Fatalf("DeleteByHash failed: %v", err)
+	}
+	if _, err := db.Get("drop"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound immediately after deleting a sealed record, got %v", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	// Sealed segments are rediscovered by loadSegments'
+	// buildIndexFromSectionOf, which reads whatever the index section
+	// says on disk - a deletion that only updated the in-memory index
+	// would silently resurrect here.
+	if _, err := reopened.Get("drop"); err != ErrNotFound {
+		t.Errorf("Expected the deleted record to stay absent after reopen, got %v", err)
+	}
+}
+
+func TestDB_Vacuum(t *testing.T) {
+	tmpfile := "test_vacuum.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("keep", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Insert("drop", []float32{4, 5, 6}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Delete("drop"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if err := db.Vacuum(); err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+
+	if db.headDeadBytes != 0 {
+		t.Errorf("Expected headDeadBytes to be reset after Vacuum, got %d", db.headDeadBytes)
+	}
+	if _, err := db.Get("keep"); err != nil {
+		t.Errorf("Expected 'keep' to survive Vacuum: %v", err)
+	}
+
+	// Vacuum just delegates to Compact, which refuses while a snapshot is
+	// open; confirm that still holds through the Vacuum entry point.
+	snap := db.NewSnapshot()
+	defer snap.Release()
+	if err := db.Vacuum(); err != ErrSnapshotsOpen {
+		t.Errorf("Expected ErrSnapshotsOpen from Vacuum with an open snapshot, got %v", err)
+	}
+}
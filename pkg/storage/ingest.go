@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"sync"
+)
+
+// ErrNoIngestQueue is returned by IngestAsync when the DB wasn't opened
+// with OpenOptions.Ingest set. FlushIngest has nothing to flush then and
+// returns nil.
+var ErrNoIngestQueue = errors.New("storage: DB has no ingest queue configured")
+
+// ingestCoalesceSize caps how many jobs an ingest worker batches into a
+// single db.Write call before flushing, bounding worst-case latency for
+// any one insert while still amortizing the write over many records.
+const ingestCoalesceSize = 64
+
+// Job is a single pending insert submitted to an IngestQueue.
+type Job struct {
+	Text   string
+	Vector []float32
+
+	// barrier, if non-nil, marks this as an internal flush marker
+	// rather than a real insert: the worker flushes its current batch
+	// and closes barrier instead of buffering Text/Vector. Used by
+	// DB.FlushIngest.
+	barrier chan struct{}
+}
+
+// IngestQueue decouples the cost of accepting an insert from the cost
+// of committing it, so a synchronous caller - notably the CGo Insert
+// shim, serializing bulk-loader clients onto one OS thread - doesn't
+// block on a disk write per call. Implementations: ChannelQueue
+// (in-process, buffered) and LevelQueue (durable, spills to disk so
+// pending inserts survive a crash).
+type IngestQueue interface {
+	// Push enqueues job. It only returns an error if the queue itself
+	// can no longer accept work (e.g. it has been closed).
+	Push(job Job) error
+	// Run drains the queue, calling handler for each job in order,
+	// until ctx is done or the queue is closed. Run blocks until then.
+	Run(ctx context.Context, handler func(Job)) error
+}
+
+// ChannelQueue is an in-process IngestQueue backed by a buffered
+// channel. Jobs are lost if the process crashes before a worker drains
+// them; use LevelQueue when that isn't acceptable.
+type ChannelQueue struct {
+	jobs   chan Job
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewChannelQueue returns a ChannelQueue whose buffer holds up to
+// bufferSize pending jobs before Push blocks.
+func NewChannelQueue(bufferSize int) *ChannelQueue {
+	return &ChannelQueue{
+		jobs:   make(chan Job, bufferSize),
+		closed: make(chan struct{}),
+	}
+}
+
+func (q *ChannelQueue) Push(job Job) error {
+	select {
+	case <-q.closed:
+		return errors.New("storage: ChannelQueue is closed")
+	default:
+	}
+
+	select {
+	case q.jobs <- job:
+		return nil
+	case <-q.closed:
+		return errors.New("storage: ChannelQueue is closed")
+	}
+}
+
+func (q *ChannelQueue) Run(ctx context.Context, handler func(Job)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.closed:
+			return nil
+		case job := <-q.jobs:
+			handler(job)
+		}
+	}
+}
+
+// Close stops the queue from accepting further Push calls and causes
+// any in-flight Run to return.
+func (q *ChannelQueue) Close() {
+	q.once.Do(func() { close(q.closed) })
+}
+
+// levelQueueBarrier is a pending FlushIngest barrier waiting for Run's
+// replay to catch up to afterOffset. Barriers are tracked in memory
+// rather than appended to the log: unlike a Job's Text/Vector, the
+// barrier's channel can't survive a round trip through encodeJob, and
+// it has no reason to - it's only ever meaningful to the process that
+// created it.
+type levelQueueBarrier struct {
+	afterOffset int64
+	done        chan struct{}
+}
+
+// LevelQueue is a durable IngestQueue, inspired by gitea's issue-indexer
+// queue: jobs are appended to a small on-disk log before Push returns,
+// so a crash between Push and the worker committing the insert doesn't
+// lose it. Run replays whatever is already on disk, then blocks for new
+// appends.
+type LevelQueue struct {
+	mu          sync.Mutex
+	file        *os.File
+	writeOffset int64 // bytes written so far; seeded from the file's size on open
+	barriers    []levelQueueBarrier
+	notify      chan struct{}
+	closed      chan struct{}
+	once        sync.Once
+}
+
+// NewLevelQueue opens (creating if necessary) the append-only log at
+// path and returns a LevelQueue backed by it.
+func NewLevelQueue(path string) (*LevelQueue, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &LevelQueue{
+		file:        f,
+		writeOffset: stat.Size(),
+		notify:      make(chan struct{}, 1),
+		closed:      make(chan struct{}),
+	}, nil
+}
+
+func (q *LevelQueue) Push(job Job) error {
+	if job.barrier != nil {
+		q.mu.Lock()
+		q.barriers = append(q.barriers, levelQueueBarrier{afterOffset: q.writeOffset, done: job.barrier})
+		q.mu.Unlock()
+
+		select {
+		case q.notify <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	buf := encodeJob(job)
+
+	q.mu.Lock()
+	n, err := q.file.WriteAt(buf, q.writeOffset)
+	if err == nil {
+		q.writeOffset += int64(n)
+	}
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *LevelQueue) Run(ctx context.Context, handler func(Job)) error {
+	var offset int64
+
+	// fireBarriers hands each due barrier to handler as a Job, exactly
+	// like any job read from the log - it's the handler's job to decide
+	// what a barrier means (runIngestDispatch fans it out to every
+	// worker and closes it once they've all flushed). LevelQueue only
+	// owns sequencing: a barrier becomes due once replay has reached the
+	// log offset it was pushed at, i.e. once every job pushed before it
+	// has already been handed to handler.
+	fireBarriers := func() {
+		q.mu.Lock()
+		remaining := q.barriers[:0]
+		var due []levelQueueBarrier
+		for _, b := range q.barriers {
+			if offset >= b.afterOffset {
+				due = append(due, b)
+			} else {
+				remaining = append(remaining, b)
+			}
+		}
+		q.barriers = remaining
+		q.mu.Unlock()
+
+		for _, b := range due {
+			handler(Job{barrier: b.done})
+		}
+	}
+
+	for {
+		q.mu.Lock()
+		stat, err := q.file.Stat()
+		q.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		for offset < stat.Size() {
+			job, n, err := q.readJobAt(offset)
+			if err != nil {
+				return err
+			}
+			handler(job)
+			offset += n
+			fireBarriers()
+		}
+		fireBarriers()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.closed:
+			return nil
+		case <-q.notify:
+		}
+	}
+}
+
+// Close closes the log file, so further Push calls fail, and causes any
+// in-flight Run to return: nil if it was waiting for new appends, or a
+// read error if it was mid-replay. Run does not drain the log first;
+// jobs it had not yet handed to its handler stay in the log on disk.
+func (q *LevelQueue) Close() error {
+	q.once.Do(func() { close(q.closed) })
+	return q.file.Close()
+}
+
+func (q *LevelQueue) readJobAt(offset int64) (Job, int64, error) {
+	header := make([]byte, 4)
+	if _, err := q.file.ReadAt(header, offset); err != nil {
+		return Job{}, 0, err
+	}
+	textLen := binary.LittleEndian.Uint32(header)
+
+	textBuf := make([]byte, textLen)
+	if textLen > 0 {
+		if _, err := q.file.ReadAt(textBuf, offset+4); err != nil {
+			return Job{}, 0, err
+		}
+	}
+
+	dimOffset := offset + 4 + int64(textLen)
+	dimBuf := make([]byte, 4)
+	if _, err := q.file.ReadAt(dimBuf, dimOffset); err != nil {
+		return Job{}, 0, err
+	}
+	dim := binary.LittleEndian.Uint32(dimBuf)
+
+	vecBuf := make([]byte, int64(dim)*4)
+	if len(vecBuf) > 0 {
+		if _, err := q.file.ReadAt(vecBuf, dimOffset+4); err != nil {
+			return Job{}, 0, err
+		}
+	}
+
+	vector := make([]float32, dim)
+	for i := range vector {
+		bits := binary.LittleEndian.Uint32(vecBuf[i*4:])
+		vector[i] = math.Float32frombits(bits)
+	}
+
+	total := int64(4) + int64(textLen) + 4 + int64(dim)*4
+	return Job{Text: string(textBuf), Vector: vector}, total, nil
+}
+
+func encodeJob(job Job) []byte {
+	textBytes := []byte(job.Text)
+	buf := make([]byte, 4+len(textBytes)+4+len(job.Vector)*4)
+
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(textBytes)))
+	copy(buf[4:], textBytes)
+
+	dimOffset := 4 + len(textBytes)
+	binary.LittleEndian.PutUint32(buf[dimOffset:dimOffset+4], uint32(len(job.Vector)))
+
+	payloadOffset := dimOffset + 4
+	for i, v := range job.Vector {
+		binary.LittleEndian.PutUint32(buf[payloadOffset+i*4:], math.Float32bits(v))
+	}
+
+	return buf
+}
+
+// IngestAsync enqueues text/vector for asynchronous insertion via the
+// DB's configured ingest queue (OpenOptions.Ingest), returning once the
+// job has been queued - not once it has been committed to disk.
+func (db *DB) IngestAsync(text string, vector []float32) error {
+	if db.ingestQueue == nil {
+		return ErrNoIngestQueue
+	}
+	return db.ingestQueue.Push(Job{Text: text, Vector: vector})
+}
+
+// FlushIngest blocks until every job pushed to the DB's ingest queue
+// before this call has been committed via Write.
+func (db *DB) FlushIngest() error {
+	if db.ingestQueue == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	if err := db.ingestQueue.Push(Job{barrier: done}); err != nil {
+		return err
+	}
+	<-done
+	return nil
+}
+
+// runIngestDispatch is the sole reader of db.ingestQueue: IngestQueue
+// implementations only guarantee ordered delivery to a single Run loop,
+// so one dispatcher drains it and fans ordinary jobs out round-robin
+// across the per-worker channels created in OpenWithOptions. A
+// FlushIngest barrier is forwarded to every worker channel (as its own
+// barrier, tail-of-queue on each) so all of them flush their local
+// batch before FlushIngest returns - fixing the prior single-worker
+// barrier, which only ever drained the one worker lucky enough to
+// receive it.
+func (db *DB) runIngestDispatch(ctx context.Context) {
+	next := 0
+
+	_ = db.ingestQueue.Run(ctx, func(job Job) {
+		if job.barrier != nil {
+			var wg sync.WaitGroup
+			for _, ch := range db.ingestWorkerChans {
+				done := make(chan struct{})
+				ch <- Job{barrier: done}
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-done
+				}()
+			}
+			wg.Wait()
+			close(job.barrier)
+			return
+		}
+
+		db.ingestWorkerChans[next] <- job
+		next = (next + 1) % len(db.ingestWorkerChans)
+	})
+}
+
+// runIngestWorker drains its own per-worker channel, coalescing
+// consecutive jobs into a single db.Write(batch) call per
+// ingestCoalesceSize jobs (or whenever a barrier forwarded by
+// runIngestDispatch arrives). Because each worker is the sole consumer
+// of its own channel, by the time its barrier is dequeued every job
+// dispatched to it earlier is guaranteed to have already been applied
+// to batch.
+func (db *DB) runIngestWorker(ctx context.Context, jobs chan Job) {
+	batch := db.NewBatch()
+
+	flush := func() {
+		if batch.Len() == 0 {
+			return
+		}
+		_ = db.Write(batch)
+		batch.Reset()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+		case job := <-jobs:
+			if job.barrier != nil {
+				flush()
+				close(job.barrier)
+				continue
+			}
+
+			batch.Put(job.Text, job.Vector)
+			if batch.Len() >= ingestCoalesceSize {
+				flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDB_IngestAsyncAndFlush(t *testing.T) {
+	tmpfile := "test_ingest.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{
+		Ingest:        NewChannelQueue(16),
+		IngestWorkers: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.IngestAsync("async-hello", []float32{0.1, 0.2, 0.3}); err != nil {
+		t.Fatalf("IngestAsync failed: %v", err)
+	}
+
+	if err := db.FlushIngest(); err != nil {
+		t.Fatalf("FlushIngest failed: %v", err)
+	}
+
+	vector, err := db.Get("async-hello")
+	if err != nil {
+		t.Fatalf("Expected record to be committed after FlushIngest: %v", err)
+	}
+	if vector[0] != 0.1 {
+		t.Errorf("Expected vector[0] == 0.1, got %f", vector[0])
+	}
+}
+
+func TestDB_IngestAsyncWithoutQueue(t *testing.T) {
+	tmpfile := "test_ingest_none.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.IngestAsync("hello", []float32{0.1, 0.2, 0.3}); err != ErrNoIngestQueue {
+		t.Errorf("Expected ErrNoIngestQueue, got %v", err)
+	}
+}
+
+func TestDB_FlushIngestWithMultipleWorkersSeesAllJobs(t *testing.T) {
+	tmpfile := "test_ingest_multi_worker.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{
+		Ingest:        NewChannelQueue(64),
+		IngestWorkers: 4,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	const n = 40
+	for i := 0; i < n; i++ {
+		text := "item-" + string(rune('a'+i%26)) + string(rune('0'+i/26))
+		if err := db.IngestAsync(text, []float32{float32(i), 0, 0}); err != nil {
+			t.Fatalf("IngestAsync failed: %v", err)
+		}
+	}
+
+	if err := db.FlushIngest(); err != nil {
+		t.Fatalf("FlushIngest failed: %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		text := "item-" + string(rune('a'+i%26)) + string(rune('0'+i/26))
+		if _, err := db.Get(text); err != nil {
+			t.Errorf("Expected %q to be committed after FlushIngest with multiple workers, got %v", text, err)
+		}
+	}
+}
+
+func TestLevelQueue_FlushIngestSeesPriorPushes(t *testing.T) {
+	tmpfile := "test_ingest_level_flush.edb"
+	logPath := "test_ingest_level_flush.log"
+	defer os.Remove(tmpfile)
+	defer os.Remove(logPath)
+
+	queue, err := NewLevelQueue(logPath)
+	if err != nil {
+		t.Fatalf("Failed to create LevelQueue: %v", err)
+	}
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{Ingest: queue})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.IngestAsync("durable-hello", []float32{0.4, 0.5, 0.6}); err != nil {
+		t.Fatalf("IngestAsync failed: %v", err)
+	}
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- db.FlushIngest() }()
+
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Fatalf("FlushIngest failed: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("FlushIngest never returned against a LevelQueue-backed DB")
+	}
+
+	if _, err := db.Get("durable-hello"); err != nil {
+		t.Fatalf("Expected 'durable-hello' to be committed after FlushIngest: %v", err)
+	}
+}
+
+func TestLevelQueue_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "ingest.log")
+
+	q1, err := NewLevelQueue(logPath)
+	if err != nil {
+		t.Fatalf("Failed to create LevelQueue: %v", err)
+	}
+	if err := q1.Push(Job{Text: "hello", Vector: []float32{1, 2, 3}}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := q1.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	q2, err := NewLevelQueue(logPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen LevelQueue: %v", err)
+	}
+	defer q2.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got Job
+	done := make(chan struct{})
+	go func() {
+		_ = q2.Run(ctx, func(job Job) {
+			got = job
+			close(done)
+		})
+	}()
+
+	<-done
+	if got.Text != "hello" || len(got.Vector) != 3 {
+		t.Errorf("Expected replayed job {hello, [1 2 3]}, got %+v", got)
+	}
+}
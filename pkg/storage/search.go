@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"container/heap"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// SearchResult is a single ranked match from SearchTopK.
+type SearchResult struct {
+	Hash uint64
+	// Text is always the zero value: like the rest of this package, DB
+	// only ever persists HashText(text), never the source text itself
+	// (see Convert's doc comment), so there's nothing to fill it from.
+	Text   string
+	Vector []float32
+	Score  float32
+}
+
+// topKHeap is a min-heap of SearchResult ordered by Score, so the
+// lowest-scoring candidate sits at heap[0] and can be evicted in
+// O(log k) once the heap reaches its bound - the standard bounded
+// top-K pattern, used independently by each SearchTopK worker and again
+// to merge their results.
+type topKHeap []SearchResult
+
+func (h topKHeap) Len() int            { return len(h) }
+func (h topKHeap) Less(i, j int) bool  { return h[i].Score < h[j].Score }
+func (h topKHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *topKHeap) Push(x interface{}) { *h = append(*h, x.(SearchResult)) }
+func (h *topKHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// offerTopK offers candidate to h, which is kept bounded at size k: h
+// grows until it reaches k elements, after which a new candidate only
+// displaces h's current minimum if it scores higher.
+func offerTopK(h *topKHeap, candidate SearchResult, k int) {
+	if h.Len() < k {
+		heap.Push(h, candidate)
+		return
+	}
+	if candidate.Score > (*h)[0].Score {
+		heap.Pop(h)
+		heap.Push(h, candidate)
+	}
+}
+
+// vectorNorm returns the Euclidean norm of v.
+func vectorNorm(v []float32) float32 {
+	var sumSq float32
+	for _, x := range v {
+		sumSq += x * x
+	}
+	return float32(math.Sqrt(float64(sumSq)))
+}
+
+// cosineSimilarityNorm is cosineSimilarity with the query's norm
+// precomputed once (by vectorNorm, passed in as queryNorm) rather than
+// recomputed per candidate,
+// since SearchTopK scores the same query vector against every candidate
+// in the database.
+func cosineSimilarityNorm(query []float32, queryNorm float32, candidate []float32) float32 {
+	if len(query) != len(candidate) {
+		return -1
+	}
+
+	var dotProduct, normB float32
+	for i := range query {
+		dotProduct += query[i] * candidate[i]
+		normB += candidate[i] * candidate[i]
+	}
+
+	if queryNorm == 0 || normB == 0 {
+		return 0
+	}
+
+	return dotProduct / (queryNorm * float32(math.Sqrt(float64(normB))))
+}
+
+// SearchTopK returns up to k records scoring at least threshold against
+// query, ranked highest score first. Unlike FindSimilar, which holds
+// db.mu.RLock() for its whole scan, scoring included,
+// SearchTopK takes the lock exactly once to copy every candidate's
+// vector out - head and sealed segments alike - then scores them fully
+// unlocked: the vectors were copied out rather than referenced by mmap
+// offset, so neither a concurrent Insert's remap nor a concurrent
+// Compact's segment merge (which munmaps old segments) can invalidate
+// them mid-score. Scoring fans out across runtime.GOMAXPROCS workers,
+// each keeping a local size-k min-heap; the workers' heaps are merged
+// into the final result at the end.
+func (db *DB) SearchTopK(query []float32, k int, threshold float32) ([]SearchResult, error) {
+	if len(query) != int(db.dimension) {
+		return nil, ErrDimensionMismatch
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	type candidate struct {
+		hash   uint64
+		vector []float32
+	}
+
+	// Gather every candidate - head and sealed segments alike - under a
+	// single db.mu.RLock. A sealed segment is only immutable while it's
+	// still referenced from db.segments: mergeSegments (compact.go)
+	// drops old segments and munmaps them under db.mu.Lock(), so reading
+	// seg.mmap after releasing db.mu here could fault on a segment a
+	// concurrent Compact had already torn down. Copying vectors out
+	// while still locked, rather than keeping raw offsets, is what lets
+	// the expensive scoring fan-out below run fully unlocked.
+	db.mu.RLock()
+	candidates := make([]candidate, 0, len(db.index))
+	for hash, offset := range db.index {
+		vector, err := db.readVector(offset)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{hash: hash, vector: vector})
+	}
+	for _, seg := range db.segments {
+		for hash, offset := range seg.index {
+			vector, err := readVectorFrom(seg.mmap, seg.header, offset)
+			if err != nil {
+				continue
+			}
+			candidates = append(candidates, candidate{hash: hash, vector: vector})
+		}
+	}
+	db.mu.RUnlock()
+
+	queryNorm := vectorNorm(query)
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(candidates) {
+		workers = len(candidates)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	chunkSize := (len(candidates) + workers - 1) / workers
+	if chunkSize == 0 {
+		chunkSize = 1
+	}
+
+	partials := make([]topKHeap, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start := w * chunkSize
+		if start >= len(candidates) {
+			break
+		}
+		end := start + chunkSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+
+		wg.Add(1)
+		go func(w int, slice []candidate) {
+			defer wg.Done()
+			local := make(topKHeap, 0, k)
+			for _, c := range slice {
+				score := cosineSimilarityNorm(query, queryNorm, c.vector)
+				if score < threshold {
+					continue
+				}
+				offerTopK(&local, SearchResult{Hash: c.hash, Vector: c.vector, Score: score}, k)
+			}
+			partials[w] = local
+		}(w, candidates[start:end])
+	}
+	wg.Wait()
+
+	merged := make(topKHeap, 0, k)
+	for _, partial := range partials {
+		for _, r := range partial {
+			offerTopK(&merged, r, k)
+		}
+	}
+
+	results := make([]SearchResult, len(merged))
+	copy(results, merged)
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
+	return results, nil
+}
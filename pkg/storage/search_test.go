@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDB_SearchTopK(t *testing.T) {
+	tmpfile := "test_search_topk.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	vectors := map[string][]float32{
+		"exact":    {1, 0, 0},
+		"close":    {0.9, 0.1, 0},
+		"orthogon": {0, 1, 0},
+		"opposite": {-1, 0, 0},
+	}
+	for text, vector := range vectors {
+		if err := db.Insert(text, vector); err != nil {
+			t.Fatalf("Failed to insert %q: %v", text, err)
+		}
+	}
+
+	results, err := db.SearchTopK([]float32{1, 0, 0}, 2, 0)
+	if err != nil {
+		t.Fatalf("SearchTopK failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Score < results[1].Score {
+		t.Errorf("Expected results sorted by descending score, got %v then %v",
+			results[0].Score, results[1].Score)
+	}
+	if results[0].Hash != HashText("exact") {
+		t.Errorf("Expected the best match to be 'exact', got hash %d", results[0].Hash)
+	}
+}
+
+func TestDB_SearchTopKAppliesThreshold(t *testing.T) {
+	tmpfile := "test_search_topk_threshold.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("same", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Insert("opposite", []float32{-1, 0, 0}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	results, err := db.SearchTopK([]float32{1, 0, 0}, 5, 0.5)
+	if err != nil {
+		t.Fatalf("SearchTopK failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected only the match above threshold, got %d results", len(results))
+	}
+	if results[0].Hash != HashText("same") {
+		t.Errorf("Expected the surviving match to be 'same', got hash %d", results[0].Hash)
+	}
+}
+
+func TestDB_SearchTopKRejectsDimensionMismatch(t *testing.T) {
+	tmpfile := "test_search_topk_mismatch.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.SearchTopK([]float32{1, 0}, 1, 0); err != ErrDimensionMismatch {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestDB_SearchTopKScansSealedSegments(t *testing.T) {
+	tmpfile := "test_search_topk_segments.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("sealed", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if len(db.segments) == 0 {
+		t.Fatal("Expected the tiny threshold to have sealed a segment")
+	}
+	if err := db.Insert("head", []float32{0.9, 0.1, 0}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	results, err := db.SearchTopK([]float32{1, 0, 0}, 2, 0)
+	if err != nil {
+		t.Fatalf("SearchTopK failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected results from both the head and the sealed segment, got %d", len(results))
+	}
+}
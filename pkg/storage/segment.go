@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// segment is an immutable, sealed chunk of the database: a self-contained
+// file with its own header, data section and index section, modeled on
+// Prometheus tsdb's block layout. New inserts only ever go to the head
+// segment (db.file/db.mmap/db.index); once the head's data section grows
+// past OpenOptions.SegmentSizeThreshold, sealHead seals it into one of
+// these and starts a fresh head, so the per-insert remap() only ever
+// rebinds a small, bounded file instead of the whole database.
+type segment struct {
+	id        uint64
+	path      string
+	file      *os.File
+	mmap      []byte // nil if the segment has no records
+	header    *Header
+	index     map[uint64]int64 // hash -> offset within mmap
+	bloom     *bloomFilter      // nil if the segment has no records
+	deadBytes int64             // bytes of this segment's own records no longer referenced by index, tracked so mergeSegments knows exactly how much of db.segDeadBytes it reclaims
+}
+
+// nextSegmentID hands out process-wide unique, monotonically increasing
+// segment ids. loadSegments advances it past any ids already present on
+// disk so ids never collide across a restart.
+var nextSegmentID uint64
+
+func newSegmentID() uint64 {
+	return atomic.AddUint64(&nextSegmentID, 1)
+}
+
+func segmentPath(dbPath string, id uint64) string {
+	return fmt.Sprintf("%s.seg.%d", dbPath, id)
+}
+
+// hashExists reports whether hash already has a live record, in the head
+// or in any sealed segment, so Insert/Batch.Put's dedup check still holds
+// once records can have been sealed away from the head. Callers must
+// hold db.mu.
+func (db *DB) hashExists(hash uint64) bool {
+	if _, exists := db.index[hash]; exists {
+		return true
+	}
+	for _, seg := range db.segments {
+		if _, exists := seg.index[hash]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// sealHead seals the current head segment into an immutable sibling file
+// and starts a fresh, empty head in its place. Callers must hold db.mu
+// for writing.
+func (db *DB) sealHead() error {
+	if len(db.index) == 0 {
+		// Nothing to seal; keep writing into the current (empty) head.
+		return db.remap()
+	}
+
+	if db.snapsList.Len() > 0 {
+		// A live Snapshot holds offsets into the current head's mmap;
+		// sealing now would munmap it out from under them. Defer the
+		// seal - like Compact, which refuses outright - until every
+		// open snapshot has been released, and keep appending to the
+		// current head in the meantime.
+		return db.remap()
+	}
+
+	if db.header.Version >= 2 {
+		if err := db.writeIndexSection(); err != nil {
+			return err
+		}
+	}
+	if _, err := db.file.WriteAt(db.header.Encode(), 0); err != nil {
+		return err
+	}
+
+	if db.mmap != nil {
+		if err := syscall.Munmap(db.mmap); err != nil {
+			return err
+		}
+		db.mmap = nil
+	}
+	if err := db.file.Sync(); err != nil {
+		return err
+	}
+	if err := db.file.Close(); err != nil {
+		return err
+	}
+
+	id := newSegmentID()
+	sealedPath := segmentPath(db.path, id)
+	if err := os.Rename(db.path, sealedPath); err != nil {
+		return err
+	}
+
+	seg, err := openSegment(sealedPath, id, db.index)
+	if err != nil {
+		return err
+	}
+	db.segments = append(db.segments, seg)
+
+	f, err := os.Create(db.path)
+	if err != nil {
+		return err
+	}
+
+	header := NewHeaderWithCodec(db.dimension, db.defaultCodec)
+	if _, err := f.Write(header.Encode()); err != nil {
+		f.Close()
+		return err
+	}
+
+	db.file = f
+	db.header = header
+	db.index = make(map[uint64]int64)
+	db.bloom = nil
+	db.dataEndOffset = HeaderSize
+
+	return nil
+}
+
+// openSegment opens path read-only and mmaps it (if non-empty), using
+// index as the segment's already-known hash->offset map (sealHead
+// already has it in memory) rather than rebuilding it from the file it
+// just wrote.
+func openSegment(path string, id uint64, index map[uint64]int64) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	headerBytes := make([]byte, HeaderSize)
+	if _, err := f.ReadAt(headerBytes, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	header := DecodeHeader(headerBytes)
+
+	var mmap []byte
+	if stat.Size() > HeaderSize {
+		mmap, err = syscall.Mmap(int(f.Fd()), 0, int(stat.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	bloom := loadOrRebuildBloom(mmap, header, index)
+
+	return &segment{id: id, path: path, file: f, mmap: mmap, header: header, index: index, bloom: bloom}, nil
+}
+
+// persistSegmentDelete rewrites seg's on-disk index section to match
+// seg.index after a hash has been dropped from it. Without this, a
+// delete against a sealed segment only ever updated the in-memory
+// index: loadSegments/buildIndexFromSectionOf rebuilds a reopened
+// segment's index straight from the on-disk index section regardless of
+// what's in memory, so the "deleted" record would silently resurrect on
+// the next Open. Segments are opened O_RDONLY, so this briefly reopens
+// the file O_RDWR to rewrite it, then closes and reopens it read-only
+// again - the same open/mmap sequence openSegment already uses. Callers
+// must hold db.mu for writing.
+func (db *DB) persistSegmentDelete(seg *segment) error {
+	dataEnd := int64(seg.header.IndexOffset)
+
+	rw, err := os.OpenFile(seg.path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	indexOffset, bloomOffset, bloomLength, err := writeIndexSectionTo(rw, seg.index, dataEnd)
+	if err != nil {
+		rw.Close()
+		return err
+	}
+
+	seg.header.IndexOffset = uint64(indexOffset)
+	seg.header.BloomOffset = uint64(bloomOffset)
+	seg.header.BloomLength = uint64(bloomLength)
+	seg.header.RecordCount = uint64(len(seg.index))
+
+	if _, err := rw.WriteAt(seg.header.Encode(), 0); err != nil {
+		rw.Close()
+		return err
+	}
+	if err := rw.Sync(); err != nil {
+		rw.Close()
+		return err
+	}
+	if err := rw.Close(); err != nil {
+		return err
+	}
+
+	if seg.mmap != nil {
+		if err := syscall.Munmap(seg.mmap); err != nil {
+			return err
+		}
+	}
+	if err := seg.file.Close(); err != nil {
+		return err
+	}
+
+	reopened, err := openSegment(seg.path, seg.id, seg.index)
+	if err != nil {
+		return err
+	}
+	reopened.deadBytes = seg.deadBytes
+	*seg = *reopened
+	return nil
+}
+
+// loadSegments rediscovers sealed segment files left behind from a
+// previous run of the process (<db.path>.seg.<id>) and rebuilds each
+// one's local index, so a reopened, segmented database picks up right
+// where it left off. It also advances nextSegmentID past any id found
+// on disk so future seals never reuse one.
+func (db *DB) loadSegments() error {
+	matches, err := filepath.Glob(db.path + ".seg.*")
+	if err != nil {
+		return err
+	}
+
+	type found struct {
+		id  uint64
+		seg *segment
+	}
+	var segs []found
+
+	prefix := db.path + ".seg."
+	for _, path := range matches {
+		id, err := strconv.ParseUint(strings.TrimPrefix(path, prefix), 10, 64)
+		if err != nil {
+			continue // not one of ours (e.g. a stray .seg.merge.tmp)
+		}
+
+		f, err := os.OpenFile(path, os.O_RDONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		headerBytes := make([]byte, HeaderSize)
+		if _, err := f.ReadAt(headerBytes, 0); err != nil {
+			f.Close()
+			return err
+		}
+		header := DecodeHeader(headerBytes)
+
+		var mmap []byte
+		if stat.Size() > HeaderSize {
+			mmap, err = syscall.Mmap(int(f.Fd()), 0, int(stat.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+			if err != nil {
+				f.Close()
+				return err
+			}
+		}
+
+		index, err := buildIndexFrom(mmap, header)
+		if err != nil {
+			return err
+		}
+		bloom := loadOrRebuildBloom(mmap, header, index)
+
+		segs = append(segs, found{id: id, seg: &segment{
+			id: id, path: path, file: f, mmap: mmap, header: header, index: index, bloom: bloom,
+		}})
+
+		for {
+			cur := atomic.LoadUint64(&nextSegmentID)
+			if id <= cur {
+				break
+			}
+			if atomic.CompareAndSwapUint64(&nextSegmentID, cur, id) {
+				break
+			}
+		}
+	}
+
+	sort.Slice(segs, func(i, j int) bool { return segs[i].id < segs[j].id })
+
+	db.segments = make([]*segment, 0, len(segs))
+	for _, s := range segs {
+		db.segments = append(db.segments, s.seg)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_InsertSealsHeadPastThreshold(t *testing.T) {
+	tmpfile := "test_segment_seal.edb"
+	defer removeWithSegments(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("a", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Insert("b", []float32{4, 5, 6}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if len(db.segments) == 0 {
+		t.Fatal("Expected at least one sealed segment after exceeding SegmentSizeThreshold")
+	}
+
+	for _, text := range []string{"a", "b"} {
+		if _, err := db.Get(text); err != nil {
+			t.Errorf("Expected %q to be readable across segments, got %v", text, err)
+		}
+	}
+
+	vector, score, err := db.FindSimilar([]float32{1, 2, 3}, 0.99)
+	if err != nil {
+		t.Fatalf("FindSimilar failed: %v", err)
+	}
+	if score < 0.99 || len(vector) != 3 {
+		t.Errorf("Expected FindSimilar to see records across segments, got vector=%v score=%f", vector, score)
+	}
+}
+
+func TestDB_SegmentsSurviveReopen(t *testing.T) {
+	tmpfile := "test_segment_reopen.edb"
+	defer removeWithSegments(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	if err := db.Insert("a", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Insert("b", []float32{4, 5, 6}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	sealedBefore := len(db.segments)
+	if sealedBefore == 0 {
+		t.Fatal("Expected sealing to have happened before reopen")
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	if len(reopened.segments) != sealedBefore {
+		t.Errorf("Expected %d sealed segments after reopen, got %d", sealedBefore, len(reopened.segments))
+	}
+	if _, err := reopened.Get("a"); err != nil {
+		t.Errorf("Expected 'a' to survive reopen: %v", err)
+	}
+	if _, err := reopened.Get("b"); err != nil {
+		t.Errorf("Expected 'b' to survive reopen: %v", err)
+	}
+}
+
+func TestDB_DeleteFromSealedSegment(t *testing.T) {
+	tmpfile := "test_segment_delete.edb"
+	defer removeWithSegments(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("a", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	// Force a seal so "a" now lives in a sealed segment.
+	if err := db.Insert("b", []float32{4, 5, 6}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if len(db.segments) == 0 {
+		t.Fatal("Expected 'a' to have been sealed into a segment")
+	}
+
+	if err := db.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := db.Get("a"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for deleted segment record, got %v", err)
+	}
+}
+
+func TestDB_MergeSegmentsOnCompact(t *testing.T) {
+	tmpfile := "test_segment_merge.edb"
+	defer removeWithSegments(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for _, text := range []string{"a", "b", "c"} {
+		if err := db.Insert(text, []float32{1, 2, 3}); err != nil {
+			t.Fatalf("Failed to insert %q: %v", text, err)
+		}
+	}
+
+	if len(db.segments) < 2 {
+		t.Fatalf("Expected at least 2 sealed segments before merge, got %d", len(db.segments))
+	}
+	before := len(db.segments)
+
+	if err := db.Compact(context.Background()); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	if len(db.segments) != before-1 {
+		t.Errorf("Expected merge to reduce segment count by 1, got %d -> %d", before, len(db.segments))
+	}
+	for _, text := range []string{"a", "b", "c"} {
+		if _, err := db.Get(text); err != nil {
+			t.Errorf("Expected %q to survive segment merge: %v", text, err)
+		}
+	}
+}
+
+// removeWithSegments cleans up a test file along with any sealed segment
+// siblings it may have created.
+func removeWithSegments(path string) {
+	os.Remove(path)
+	matches, _ := filepath.Glob(path + ".seg.*")
+	for _, m := range matches {
+		os.Remove(m)
+	}
+}
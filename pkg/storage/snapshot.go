@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Snapshot is a read-only, point-in-time view of a DB. Get and
+// FindSimilar called on a Snapshot only see records that existed at the
+// moment NewSnapshot was called, even while the parent DB continues to
+// accept concurrent inserts. This mirrors goleveldb's snapshot model:
+// live snapshots are tracked on the DB (snapsList) so that future
+// compaction/vacuum passes know which offsets are still pinned by a
+// reader and must not be reclaimed yet.
+type Snapshot struct {
+	db       *DB
+	index    map[uint64]int64 // frozen copy of db.index at creation time
+	segments []*segment       // frozen copy of db.segments at creation time
+
+	mu       sync.Mutex
+	elem     *list.Element
+	released bool
+}
+
+// NewSnapshot returns a new Snapshot of db's current contents. The
+// caller must call Release on the returned snapshot once it is no
+// longer needed; db.Close refuses to close the DB while any snapshot is
+// still open.
+func (db *DB) NewSnapshot() *Snapshot {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	frozen := make(map[uint64]int64, len(db.index))
+	for hash, offset := range db.index {
+		frozen[hash] = offset
+	}
+
+	// Sealed segments created after this point must stay invisible to
+	// the snapshot, so the slice is copied rather than referenced live;
+	// a segment already sealed at this point can't be dropped out from
+	// under the copy either, since Compact (the only thing that merges
+	// and drops segments) refuses to run while any snapshot is open.
+	segs := append([]*segment(nil), db.segments...)
+
+	snap := &Snapshot{db: db, index: frozen, segments: segs}
+	snap.elem = db.snapsList.PushBack(snap)
+	return snap
+}
+
+// Get looks up text's vector as of the moment the snapshot was taken.
+// It returns ErrNotFound if text had no entry at that point, even if a
+// later Insert has since added one.
+func (s *Snapshot) Get(text string) ([]float32, error) {
+	hash := HashText(text)
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	if offset, exists := s.index[hash]; exists {
+		return s.db.readVector(offset)
+	}
+
+	for i := len(s.segments) - 1; i >= 0; i-- {
+		seg := s.segments[i]
+		if offset, exists := seg.index[hash]; exists {
+			return readVectorFrom(seg.mmap, seg.header, offset)
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// FindSimilar scans only the records visible to this snapshot - the
+// frozen head plus every segment sealed by the time the snapshot was
+// taken - so the result is stable regardless of concurrent writers on
+// the parent DB.
+func (s *Snapshot) FindSimilar(vector []float32, threshold float32) ([]float32, float32, error) {
+	if len(vector) != int(s.db.dimension) {
+		return nil, 0, ErrDimensionMismatch
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	offsets := make([]int64, 0, len(s.index))
+	for _, offset := range s.index {
+		offsets = append(offsets, offset)
+	}
+
+	var bestVector []float32
+	var bestScore float32 = -1
+
+	for _, offset := range offsets {
+		cached, err := s.db.readVector(offset)
+		if err != nil {
+			continue
+		}
+
+		score := cosineSimilarity(vector, cached)
+		if score > bestScore {
+			bestScore = score
+			bestVector = cached
+		}
+	}
+
+	for _, seg := range s.segments {
+		for _, offset := range seg.index {
+			cached, err := readVectorFrom(seg.mmap, seg.header, offset)
+			if err != nil {
+				continue
+			}
+			if score := cosineSimilarity(vector, cached); score > bestScore {
+				bestScore = score
+				bestVector = cached
+			}
+		}
+	}
+
+	if bestScore >= threshold {
+		return bestVector, bestScore, nil
+	}
+
+	return nil, 0, ErrNotFound
+}
+
+// Release drops the snapshot. It is safe to call more than once; only
+// the first call has any effect.
+func (s *Snapshot) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.released {
+		return
+	}
+	s.released = true
+
+	s.db.mu.Lock()
+	s.db.snapsList.Remove(s.elem)
+	s.db.mu.Unlock()
+}
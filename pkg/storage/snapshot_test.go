@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDB_SnapshotIsolation(t *testing.T) {
+	tmpfile := "test_snapshot.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("before", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	snap := db.NewSnapshot()
+	defer snap.Release()
+
+	if err := db.Insert("after", []float32{0, 1, 0}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if _, err := snap.Get("before"); err != nil {
+		t.Errorf("Expected snapshot to see pre-existing record: %v", err)
+	}
+	if _, err := snap.Get("after"); err != ErrNotFound {
+		t.Errorf("Expected snapshot to not see record inserted after it was taken, got %v", err)
+	}
+
+	if _, err := db.Get("after"); err != nil {
+		t.Errorf("Expected live DB to see record inserted after snapshot: %v", err)
+	}
+}
+
+func TestDB_SnapshotSeesSealedSegments(t *testing.T) {
+	tmpfile := "test_snapshot_segments.edb"
+	defer removeWithSegments(tmpfile)
+
+	db, err := OpenWithOptions(tmpfile, 3, OpenOptions{SegmentSizeThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert("sealed", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if len(db.segments) == 0 {
+		t.Fatal("Expected the tiny threshold to have sealed a segment")
+	}
+
+	snap := db.NewSnapshot()
+	defer snap.Release()
+
+	if vector, err := snap.Get("sealed"); err != nil || vector[0] != 1 {
+		t.Errorf("Expected snapshot to see a record already sealed into a segment: %v %v", vector, err)
+	}
+
+	result, score, err := snap.FindSimilar([]float32{1, 0, 0}, 0.9)
+	if err != nil {
+		t.Fatalf("Expected FindSimilar to find the sealed record: %v", err)
+	}
+	if score < 0.9 {
+		t.Errorf("Expected a high-confidence match, got score %f", score)
+	}
+	if result[0] != 1 {
+		t.Errorf("Expected the matched vector to be the sealed record, got %v", result)
+	}
+}
+
+func TestDB_CloseFailsWithOpenSnapshot(t *testing.T) {
+	tmpfile := "test_snapshot_close.edb"
+	defer os.Remove(tmpfile)
+
+	db, err := Open(tmpfile, 3)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	snap := db.NewSnapshot()
+
+	if err := db.Close(); err != ErrSnapshotsOpen {
+		t.Fatalf("Expected ErrSnapshotsOpen, got %v", err)
+	}
+
+	snap.Release()
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Expected Close to succeed after snapshot release: %v", err)
+	}
+}
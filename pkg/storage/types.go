@@ -7,13 +7,23 @@ import (
 
 const (
 	MagicBytes      = "EDB\x00"
-	CurrentVersion  = 2
+	CurrentVersion  = 3
 	HeaderSize      = 256
-	RecordMetaSize  = 16 // Hash(8) + Dimension(4) + Reserved(4)
+	RecordMetaSize  = 16 // Hash(8) + Dimension(4) + Reserved(4) -- version 1/2 only
 	IndexEntrySize  = 16 // Hash(8) + Offset(8)
+
+	// RecordHeaderV3Size is the fixed-size header prefixing every
+	// record in format version 3+: Hash(8) + Codec(1) + CompressedLen(4)
+	// + UncompressedLen(4). The vector's dimension is implied by
+	// UncompressedLen/4 rather than stored directly, since it no longer
+	// needs to fit in a single byte-aligned field.
+	RecordHeaderV3Size = 17
 )
 
-// Header is the file header (fixed 256 bytes)
+// Header is the file header (fixed 256 bytes). Version 2 files are read
+// using the legacy fixed-size record layout (RecordMetaSize); version 3
+// files use the variable-length, per-record-codec layout described by
+// RecordHeaderV3Size and Codec.
 type Header struct {
 	Magic       [4]byte
 	Version     uint32
@@ -21,7 +31,10 @@ type Header struct {
 	RecordCount uint64
 	IndexOffset uint64 // Offset to index section
 	DataOffset  uint64 // Offset to data section
-	Reserved    [216]byte
+	Codec       byte   // default CodecKind for new records (version 3+)
+	BloomOffset uint64 // Offset to Bloom filter block, 0 if none was written
+	BloomLength uint64 // Byte length of the Bloom filter block
+	Reserved    [199]byte
 }
 
 // IndexEntry is a single entry in the index section
@@ -52,6 +65,9 @@ func (h *Header) Encode() []byte {
 	binary.LittleEndian.PutUint64(buf[12:20], h.RecordCount)
 	binary.LittleEndian.PutUint64(buf[20:28], h.IndexOffset)
 	binary.LittleEndian.PutUint64(buf[28:36], h.DataOffset)
+	buf[36] = h.Codec
+	binary.LittleEndian.PutUint64(buf[37:45], h.BloomOffset)
+	binary.LittleEndian.PutUint64(buf[45:53], h.BloomLength)
 	return buf
 }
 
@@ -63,15 +79,28 @@ func DecodeHeader(buf []byte) *Header {
 	h.RecordCount = binary.LittleEndian.Uint64(buf[12:20])
 	h.IndexOffset = binary.LittleEndian.Uint64(buf[20:28])
 	h.DataOffset = binary.LittleEndian.Uint64(buf[28:36])
+	h.Codec = buf[36]
+	h.BloomOffset = binary.LittleEndian.Uint64(buf[37:45])
+	h.BloomLength = binary.LittleEndian.Uint64(buf[45:53])
 	return h
 }
 
+// NewHeader builds a fresh version-3 header using CodecRaw for new
+// records. Use NewHeaderWithCodec to pick a different default codec
+// (e.g. CodecSnappy via OpenOptions.Compression).
 func NewHeader(dimension uint32) *Header {
+	return NewHeaderWithCodec(dimension, CodecRaw)
+}
+
+// NewHeaderWithCodec builds a fresh version-3 header whose new records
+// default to codec unless overridden per-record.
+func NewHeaderWithCodec(dimension uint32, codec CodecKind) *Header {
 	h := &Header{
 		Version:     CurrentVersion,
 		Dimension:   dimension,
 		IndexOffset: 0, // Will be set when closing
 		DataOffset:  HeaderSize,
+		Codec:       byte(codec),
 	}
 	copy(h.Magic[:], MagicBytes)
 	return h